@@ -1,26 +1,32 @@
-
 package pb
 
 import (
-	"context"
-	"encoding/json"
-	"errors"
-	"io/ioutil"
-	"reflect"
-	"testing"
-	"time"
-
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
+	context "context"
+	errors "errors"
+	fmt "fmt"
+	grpc "google.golang.org/grpc"
+	metadata "google.golang.org/grpc/metadata"
+	proto "google.golang.org/protobuf/proto"
+	io "io"
+	testing "testing"
+	time "time"
 )
 
 // SampleTestRunner is a runner to run the Sample service test.
 type SampleTestRunner struct {
 	Client SampleClient
+	// Match is the match mode of the test cases without match, "exact" or "partial". If it is empty, "exact" is used.
+	Match string
+	// Tags selects the test cases by their tags, such as "smoke,!slow" which selects the test cases with the tag smoke
+	// and without the tag slow. If it is empty, the environment variable STEST_TAGS is used.
+	Tags string
+	// ContinueOnFailure makes the test cases without continue_on_failure report all the failed assertions of all the
+	// iterations with t.Errorf, instead of stopping at the first failure with t.Fatal.
+	ContinueOnFailure bool
 }
 
-// NewTestClient returns new SampleRunner.
-func NewTestClient(client SampleClient) *SampleTestRunner {
+// NewSampleTestRunner returns new SampleTestRunner.
+func NewSampleTestRunner(client SampleClient) *SampleTestRunner {
 	return &SampleTestRunner{
 		Client: client,
 	}
@@ -28,193 +34,825 @@ func NewTestClient(client SampleClient) *SampleTestRunner {
 
 // RunGRPCTest sends a gPRC request according to the scenario written in the JSON file and tests the response.
 // compareFuncMap takes a gRPC method name as a key and value has a function that compares expected response and actual response and return an error.
+// The responses are given to the function as pointers such as *HelloResponse.
+// If a method has no function, the responses are compared with proto.Equal and the diff is reported on failure.
+// The variables captured by a test case are shared by the following test cases of the scenario.
+// The whole scenario is validated before any request is sent, and the errors fail the test with their locations in the file.
 func (runner *SampleTestRunner) RunGRPCTest(t *testing.T, jsonPath string, compareFuncMap map[string]*func(expectedResponse, response interface{}) error) {
-	scenarioData, err := ioutil.ReadFile(jsonPath)
+	scenario, err := loadScenario(jsonPath, runner.methods())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	skipReasons, err := skipReasons(scenario, runner.Tags)
 	if err != nil {
-		panic(err)
+		t.Fatal(err.Error())
 	}
-	var scenario []map[string]interface{}
-	json.Unmarshal(scenarioData, &scenario)
-	for _, testCase := range scenario {
+	variables := map[string]interface{}{}
+	for i, testCase := range scenario {
 		ctx := context.Background()
-		runner.runTest(ctx, t, testCase, compareFuncMap)
+		runner.runTest(ctx, t, i, testCase, variables, compareFuncMap, skipReasons[i])
 	}
 }
 
-const (
-	actionJSONKey            = "action"
-	requestJSONKey           = "request"
-	expectedResponseJSONKey  = "expected_response"
-	errorExpectationJSONKey  = "error_expectation"
-	expectedErrorCodeJSONKey = "expected_error_code"
-	loopJSONKey              = "loop"
-	sleepJSONKey             = "sleep"
-	successRuleJSONKey       = "success_rule"
-	successRuleAll           = "all"
-	successRuleOnce          = "once"
-)
+// methods maps the names of the methods of the service to their kinds and the types of their messages.
+func (runner *SampleTestRunner) methods() map[string]serviceMethod {
+	return map[string]serviceMethod{
+		"Hello":     {kind: methodUnary, request: &HelloRequest{}, response: &HelloResponse{}},
+		"Bye":       {kind: methodUnary, request: &ByeRequest{}, response: &ByeResponse{}},
+		"Countdown": {kind: methodServerStreaming, request: &CountdownRequest{}, response: &CountdownResponse{}},
+		"Sum":       {kind: methodClientStreaming, request: &SumRequest{}, response: &SumResponse{}},
+		"Echo":      {kind: methodBidiStreaming, request: &EchoRequest{}, response: &EchoResponse{}},
+		"Profile":   {kind: methodUnary, request: &ProfileRequest{}, response: &ProfileResponse{}},
+		"Wait":      {kind: methodUnary, request: &WaitRequest{}, response: &WaitResponse{}},
+	}
+}
 
-func (runner *SampleTestRunner) runTest(ctx context.Context, t *testing.T, testCase map[string]interface{}, compareFuncMap map[string]*func(expectedResponse, response interface{}) error) {
-	var action string
-	if v, ok := testCase[actionJSONKey]; ok {
-		action = v.(string)
-	} else {
-		panic("Scenario JSON is invalid. Because action is required.")
+// runTest runs the test case at index of the scenario as the subtest named after its name or action.
+// The variables in the test case are expanded with variables, and the captured values are stored into it.
+// If skipReason is not empty, the test case is skipped for the reason.
+func (runner *SampleTestRunner) runTest(ctx context.Context, t *testing.T, index int, testCase map[string]interface{}, variables map[string]interface{}, compareFuncMap map[string]*func(expectedResponse, response interface{}) error, skipReason string) {
+	action := testCase[actionJSONKey].(string)
+	name := action
+	if v, ok := testCase[nameJSONKey]; ok {
+		name = v.(string)
 	}
 	f := func(t *testing.T) {
+		if skipReason != "" {
+			t.Skip(skipReason)
+		}
+		testCase, err := expandVariables(testCase, variables, index)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		ctx, err := outgoingContext(ctx, testCase, index)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
 		switch action {
 		case "Hello":
 			compareFunc := compareFuncMap["Hello"]
-			runner.testHello(ctx, t, testCase, compareFunc)
+			runner.testHello(ctx, t, index, testCase, variables, compareFunc)
 		case "Bye":
 			compareFunc := compareFuncMap["Bye"]
-			runner.testBye(ctx, t, testCase, compareFunc)
+			runner.testBye(ctx, t, index, testCase, variables, compareFunc)
+		case "Countdown":
+			compareFunc := compareFuncMap["Countdown"]
+			runner.testCountdown(ctx, t, index, testCase, variables, compareFunc)
+		case "Sum":
+			compareFunc := compareFuncMap["Sum"]
+			runner.testSum(ctx, t, index, testCase, variables, compareFunc)
+		case "Echo":
+			compareFunc := compareFuncMap["Echo"]
+			runner.testEcho(ctx, t, index, testCase, variables, compareFunc)
+		case "Profile":
+			compareFunc := compareFuncMap["Profile"]
+			runner.testProfile(ctx, t, index, testCase, variables, compareFunc)
+		case "Wait":
+			compareFunc := compareFuncMap["Wait"]
+			runner.testWait(ctx, t, index, testCase, variables, compareFunc)
+		default:
+			t.Fatalf("case #%d: %v", index, unknownActionError(action, runner.methods()))
 		}
 	}
-	t.Run(action, f)
+	t.Run(name, f)
 }
 
-func (runner *SampleTestRunner) testHello(ctx context.Context, t *testing.T, testCase map[string]interface{}, compareFunc *func(expectedResponse, response interface{}) error) {
-	reqJSON, reqErr := json.Marshal(testCase[requestJSONKey])
-	if reqErr != nil {
-		panic(reqErr)
-	}
+func (runner *SampleTestRunner) testHello(ctx context.Context, t *testing.T, index int, testCase map[string]interface{}, variables map[string]interface{}, compareFunc *func(expectedResponse, response interface{}) error) {
 	req := HelloRequest{}
-	json.Unmarshal(reqJSON, &req)
+	if err := decodeMessage(testCase[requestJSONKey], &req, index, requestJSONKey); err != nil {
+		t.Fatal(err.Error())
+	}
+	expectedRes := HelloResponse{}
+	if err := decodeExpectedMessage(testCase[expectedResponseJSONKey], &expectedRes, index, expectedResponseJSONKey); err != nil {
+		t.Fatal(err.Error())
+	}
+	match, err := matchMode(testCase, runner.Match)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	timeout, maxLatency, err := callLimits(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
 
-	loop := 1
-	if v, ok := testCase[loopJSONKey]; ok {
-		loop = int(v.(float64))
+	schedule, err := newSchedule(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
 	}
-FOR_LABEL:
-	for i := 1; i <= loop; i++ {
-		sleep := 0
-		if v, ok := testCase[sleepJSONKey]; ok {
-			sleep = int(v.(float64))
+	reporter := newCaseReporter(t, testCase, runner.ContinueOnFailure)
+	defer reporter.summarize()
+	for i := 1; schedule.next(i); i++ {
+		var header, trailer metadata.MD
+		callCtx, cancel := callContext(ctx, timeout)
+		start := time.Now()
+		res, err := runner.Client.Hello(callCtx, &req, grpc.Header(&header), grpc.Trailer(&trailer))
+		latency := time.Since(start)
+		cancel()
+
+		errExpectation := false
+		if v, ok := testCase[errorExpectationJSONKey]; ok {
+			errExpectation = v.(bool)
+		}
+		var failures []error
+		if errExpectation {
+			if mismatch := checkError(testCase, index, match, err); mismatch != nil {
+				failures = append(failures, fmt.Errorf("the error of the response of Hello is not as expected: %v", mismatch))
+			}
+			failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+			if len(failures) == 0 {
+				if err := captureVariables(testCase, variables, index, err, res); err != nil {
+					failures = append(failures, err)
+				}
+			}
+		} else {
+			if err != nil {
+				err = fmt.Errorf("the response of Hello was an unexpected error: %s", describeError(err))
+			} else if compareFunc != nil {
+				compare := *compareFunc
+				err = compare(&expectedRes, res)
+			} else if diff := diffResponses(match, testCase[expectedResponseJSONKey], &expectedRes, res); diff != "" {
+				err = fmt.Errorf("the actual response of the Hello was not equal to the expected response (-expected +actual):\n%s", diff)
+			}
+			if err != nil {
+				failures = append(failures, err)
+			}
+			failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+			if len(failures) == 0 {
+				if err := captureVariables(testCase, variables, index, nil, res); err != nil {
+					failures = append(failures, err)
+				}
+			}
 		}
-		time.Sleep(time.Duration(sleep) * time.Second)
 
-		res, err := runner.Client.Hello(ctx, &req)
+		if !reportIteration(reporter, schedule, i, failures) {
+			break
+		}
+	}
+}
+
+func (runner *SampleTestRunner) testBye(ctx context.Context, t *testing.T, index int, testCase map[string]interface{}, variables map[string]interface{}, compareFunc *func(expectedResponse, response interface{}) error) {
+	req := ByeRequest{}
+	if err := decodeMessage(testCase[requestJSONKey], &req, index, requestJSONKey); err != nil {
+		t.Fatal(err.Error())
+	}
+	expectedRes := ByeResponse{}
+	if err := decodeExpectedMessage(testCase[expectedResponseJSONKey], &expectedRes, index, expectedResponseJSONKey); err != nil {
+		t.Fatal(err.Error())
+	}
+	match, err := matchMode(testCase, runner.Match)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	timeout, maxLatency, err := callLimits(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	schedule, err := newSchedule(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	reporter := newCaseReporter(t, testCase, runner.ContinueOnFailure)
+	defer reporter.summarize()
+	for i := 1; schedule.next(i); i++ {
+		var header, trailer metadata.MD
+		callCtx, cancel := callContext(ctx, timeout)
+		start := time.Now()
+		res, err := runner.Client.Bye(callCtx, &req, grpc.Header(&header), grpc.Trailer(&trailer))
+		latency := time.Since(start)
+		cancel()
 
 		errExpectation := false
 		if v, ok := testCase[errorExpectationJSONKey]; ok {
 			errExpectation = v.(bool)
 		}
+		var failures []error
 		if errExpectation {
-			errCodeF := testCase[expectedErrorCodeJSONKey].(float64)
-			errCodeU := uint32(errCodeF)
-			expectedErrCode := codes.Code(errCodeU)
-			if expectedErrCode != grpc.Code(err) {
-				t.Fatalf("the error code of the response of Hello is not as expected. Expected: %d, Actual: %d\n", expectedErrCode, grpc.Code(err))
+			if mismatch := checkError(testCase, index, match, err); mismatch != nil {
+				failures = append(failures, fmt.Errorf("the error of the response of Bye is not as expected: %v", mismatch))
+			}
+			failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+			if len(failures) == 0 {
+				if err := captureVariables(testCase, variables, index, err, res); err != nil {
+					failures = append(failures, err)
+				}
 			}
-			break FOR_LABEL
 		} else {
-			resJSON, resErr := json.Marshal(testCase[expectedResponseJSONKey])
-			if resErr != nil {
-				panic(resErr)
+			if err != nil {
+				err = fmt.Errorf("the response of Bye was an unexpected error: %s", describeError(err))
+			} else if compareFunc != nil {
+				compare := *compareFunc
+				err = compare(&expectedRes, res)
+			} else if diff := diffResponses(match, testCase[expectedResponseJSONKey], &expectedRes, res); diff != "" {
+				err = fmt.Errorf("the actual response of the Bye was not equal to the expected response (-expected +actual):\n%s", diff)
 			}
-			expectedRes := HelloResponse{}
-			json.Unmarshal(resJSON, &expectedRes)
-			successRule := successRuleAll
-			if v, ok := testCase[successRuleJSONKey]; ok {
-				successRule = v.(string)
+			if err != nil {
+				failures = append(failures, err)
 			}
-			var err error
-			if compareFunc != nil {
-				compare := *compareFunc
-				err = compare(expectedRes, *res)
-			} else {
-				if !reflect.DeepEqual(expectedRes, *res) {
-					err = errors.New("the actual response of the Hello was not equal to the expected response")
+			failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+			if len(failures) == 0 {
+				if err := captureVariables(testCase, variables, index, nil, res); err != nil {
+					failures = append(failures, err)
 				}
 			}
+		}
 
-			switch successRule {
-			case successRuleAll:
-				if err != nil {
-					t.Fatal(err.Error())
+		if !reportIteration(reporter, schedule, i, failures) {
+			break
+		}
+	}
+}
+
+func (runner *SampleTestRunner) testCountdown(ctx context.Context, t *testing.T, index int, testCase map[string]interface{}, variables map[string]interface{}, compareFunc *func(expectedResponse, response interface{}) error) {
+	req := CountdownRequest{}
+	if err := decodeMessage(testCase[requestJSONKey], &req, index, requestJSONKey); err != nil {
+		t.Fatal(err.Error())
+	}
+	values, _ := testCase[expectedResponsesJSONKey].([]interface{})
+	expectedResponses := make([]CountdownResponse, len(values))
+	for j, v := range values {
+		if err := decodeExpectedMessage(v, &expectedResponses[j], index, fmt.Sprintf("%s[%d]", expectedResponsesJSONKey, j)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	match, err := matchMode(testCase, runner.Match)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	timeout, maxLatency, err := callLimits(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	schedule, err := newSchedule(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	reporter := newCaseReporter(t, testCase, runner.ContinueOnFailure)
+	defer reporter.summarize()
+	for i := 1; schedule.next(i); i++ {
+		var header, trailer metadata.MD
+		callCtx, cancel := callContext(ctx, timeout)
+		start := time.Now()
+		responses, err := runner.recvCountdown(callCtx, &req, grpc.Header(&header), grpc.Trailer(&trailer))
+		latency := time.Since(start)
+		cancel()
+		received := make([]proto.Message, len(responses))
+		for j, res := range responses {
+			received[j] = res
+		}
+
+		errExpectation := false
+		if v, ok := testCase[errorExpectationJSONKey]; ok {
+			errExpectation = v.(bool)
+		}
+		var failures []error
+		if errExpectation {
+			if mismatch := checkError(testCase, index, match, err); mismatch != nil {
+				failures = append(failures, fmt.Errorf("the final status of the stream of Countdown is not as expected: %v", mismatch))
+			}
+			if _, ok := testCase[expectedResponsesJSONKey]; ok {
+				if err := runner.compareCountdownResponses(testCase, match, expectedResponses, responses, compareFunc); err != nil {
+					failures = append(failures, err)
 				}
-			case successRuleOnce:
-				if i == loop && err != nil {
-					t.Fatal(err.Error())
+			}
+			failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+			if len(failures) == 0 {
+				if err := captureVariables(testCase, variables, index, err, received...); err != nil {
+					failures = append(failures, err)
 				}
-				if err == nil {
-					break FOR_LABEL
+			}
+		} else {
+			if err != nil {
+				err = fmt.Errorf("the stream of Countdown was terminated with an unexpected error: %s", describeError(err))
+			} else {
+				err = runner.compareCountdownResponses(testCase, match, expectedResponses, responses, compareFunc)
+			}
+			if err != nil {
+				failures = append(failures, err)
+			}
+			failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+			if len(failures) == 0 {
+				if err := captureVariables(testCase, variables, index, nil, received...); err != nil {
+					failures = append(failures, err)
 				}
 			}
 		}
+
+		if !reportIteration(reporter, schedule, i, failures) {
+			break
+		}
 	}
 }
 
-func (runner *SampleTestRunner) testBye(ctx context.Context, t *testing.T, testCase map[string]interface{}, compareFunc *func(expectedResponse, response interface{}) error) {
-	reqJSON, reqErr := json.Marshal(testCase[requestJSONKey])
-	if reqErr != nil {
-		panic(reqErr)
+// recvCountdown calls Countdown with opts and receives the responses until the stream is closed.
+// The returned error is the final status of the stream, or nil if the stream ended with io.EOF.
+func (runner *SampleTestRunner) recvCountdown(ctx context.Context, req *CountdownRequest, opts ...grpc.CallOption) ([]*CountdownResponse, error) {
+	stream, err := runner.Client.Countdown(ctx, req, opts...)
+	if err != nil {
+		return nil, err
 	}
-	req := ByeRequest{}
-	json.Unmarshal(reqJSON, &req)
+	var responses []*CountdownResponse
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return responses, nil
+		}
+		if err != nil {
+			return responses, err
+		}
+		responses = append(responses, res)
+	}
+}
 
-	loop := 1
-	if v, ok := testCase[loopJSONKey]; ok {
-		loop = int(v.(float64))
+// compareCountdownResponses compares the responses received from the stream of Countdown with expected_responses.
+// The responses are compared according to match unless compareFunc is given.
+func (runner *SampleTestRunner) compareCountdownResponses(testCase map[string]interface{}, match string, expectedResponses []CountdownResponse, responses []*CountdownResponse, compareFunc *func(expectedResponse, response interface{}) error) error {
+	if len(expectedResponses) != len(responses) {
+		return fmt.Errorf("the number of the responses of Countdown is not as expected. Expected: %d, Actual: %d", len(expectedResponses), len(responses))
 	}
-FOR_LABEL:
-	for i := 1; i <= loop; i++ {
-		sleep := 0
-		if v, ok := testCase[sleepJSONKey]; ok {
-			sleep = int(v.(float64))
+	values, _ := testCase[expectedResponsesJSONKey].([]interface{})
+	compare := func(j int, res *CountdownResponse) error {
+		if compareFunc != nil {
+			compare := *compareFunc
+			return compare(&expectedResponses[j], res)
+		}
+		if diff := diffResponses(match, values[j], &expectedResponses[j], res); diff != "" {
+			return fmt.Errorf("the actual response of the Countdown was not equal to the expected response (-expected +actual):\n%s", diff)
 		}
-		time.Sleep(time.Duration(sleep) * time.Second)
+		return nil
+	}
 
-		res, err := runner.Client.Bye(ctx, &req)
+	responseOrder := responseOrderOrdered
+	if v, ok := testCase[responseOrderJSONKey]; ok {
+		responseOrder = v.(string)
+	}
+	switch responseOrder {
+	case responseOrderOrdered:
+		for j, res := range responses {
+			if err := compare(j, res); err != nil {
+				return fmt.Errorf("response #%d: %v", j, err)
+			}
+		}
+	case responseOrderUnordered:
+		matched := make([]bool, len(responses))
+	EXPECTED_LABEL:
+		for j := range expectedResponses {
+			for k, res := range responses {
+				if !matched[k] && compare(j, res) == nil {
+					matched[k] = true
+					continue EXPECTED_LABEL
+				}
+			}
+			return fmt.Errorf("the expected response #%d of Countdown was not found in the actual responses", j)
+		}
+	default:
+		return fmt.Errorf("%s must be %q or %q, but got %q", responseOrderJSONKey, responseOrderOrdered, responseOrderUnordered, responseOrder)
+	}
+	return nil
+}
+
+func (runner *SampleTestRunner) testSum(ctx context.Context, t *testing.T, index int, testCase map[string]interface{}, variables map[string]interface{}, compareFunc *func(expectedResponse, response interface{}) error) {
+	var requests []*SumRequest
+	var sleeps []time.Duration
+	for j, r := range testCase[requestsJSONKey].([]interface{}) {
+		request := r.(map[string]interface{})
+		req := SumRequest{}
+		if err := decodeMessage(request[requestJSONKey], &req, index, fmt.Sprintf("%s[%d].%s", requestsJSONKey, j, requestJSONKey)); err != nil {
+			t.Fatal(err.Error())
+		}
+		requests = append(requests, &req)
+		sleep, err := durationField(request, sleepJSONKey)
+		if err != nil {
+			t.Fatalf("case #%d: %s[%d].%v", index, requestsJSONKey, j, err)
+		}
+		sleeps = append(sleeps, sleep)
+	}
+	expectedRes := SumResponse{}
+	if err := decodeExpectedMessage(testCase[expectedResponseJSONKey], &expectedRes, index, expectedResponseJSONKey); err != nil {
+		t.Fatal(err.Error())
+	}
+	match, err := matchMode(testCase, runner.Match)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	timeout, maxLatency, err := callLimits(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	schedule, err := newSchedule(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	reporter := newCaseReporter(t, testCase, runner.ContinueOnFailure)
+	defer reporter.summarize()
+	for i := 1; schedule.next(i); i++ {
+		var header, trailer metadata.MD
+		callCtx, cancel := callContext(ctx, timeout)
+		start := time.Now()
+		res, err := runner.sendSum(callCtx, requests, sleeps, grpc.Header(&header), grpc.Trailer(&trailer))
+		latency := time.Since(start)
+		cancel()
 
 		errExpectation := false
 		if v, ok := testCase[errorExpectationJSONKey]; ok {
 			errExpectation = v.(bool)
 		}
+		var failures []error
 		if errExpectation {
-			errCodeF := testCase[expectedErrorCodeJSONKey].(float64)
-			errCodeU := uint32(errCodeF)
-			expectedErrCode := codes.Code(errCodeU)
-			if expectedErrCode != grpc.Code(err) {
-				t.Fatalf("the error code of the response of Bye is not as expected. Expected: %d, Actual: %d\n", expectedErrCode, grpc.Code(err))
+			if mismatch := checkError(testCase, index, match, err); mismatch != nil {
+				failures = append(failures, fmt.Errorf("the error of the response of Sum is not as expected: %v", mismatch))
+			}
+			failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+			if len(failures) == 0 {
+				if err := captureVariables(testCase, variables, index, err, res); err != nil {
+					failures = append(failures, err)
+				}
 			}
-			break FOR_LABEL
 		} else {
-			resJSON, resErr := json.Marshal(testCase[expectedResponseJSONKey])
-			if resErr != nil {
-				panic(resErr)
+			if err != nil {
+				err = fmt.Errorf("the response of Sum was an unexpected error: %s", describeError(err))
+			} else if compareFunc != nil {
+				compare := *compareFunc
+				err = compare(&expectedRes, res)
+			} else if diff := diffResponses(match, testCase[expectedResponseJSONKey], &expectedRes, res); diff != "" {
+				err = fmt.Errorf("the actual response of the Sum was not equal to the expected response (-expected +actual):\n%s", diff)
 			}
-			expectedRes := ByeResponse{}
-			json.Unmarshal(resJSON, &expectedRes)
-			successRule := successRuleAll
-			if v, ok := testCase[successRuleJSONKey]; ok {
-				successRule = v.(string)
+			if err != nil {
+				failures = append(failures, err)
 			}
-			var err error
-			if compareFunc != nil {
-				compare := *compareFunc
-				err = compare(expectedRes, *res)
-			} else {
-				if !reflect.DeepEqual(expectedRes, *res) {
-					err = errors.New("the actual response of the Bye was not equal to the expected response")
+			failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+			if len(failures) == 0 {
+				if err := captureVariables(testCase, variables, index, nil, res); err != nil {
+					failures = append(failures, err)
 				}
 			}
+		}
+
+		if !reportIteration(reporter, schedule, i, failures) {
+			break
+		}
+	}
+}
+
+// sendSum calls Sum with opts, sends the requests in order and receives the response.
+// sleeps[j] is the duration to sleep before sending requests[j].
+func (runner *SampleTestRunner) sendSum(ctx context.Context, requests []*SumRequest, sleeps []time.Duration, opts ...grpc.CallOption) (*SumResponse, error) {
+	stream, err := runner.Client.Sum(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for j, req := range requests {
+		time.Sleep(sleeps[j])
+		if err := stream.Send(req); err != nil {
+			// io.EOF means that the server has closed the stream.
+			// The status is returned from CloseAndRecv.
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+func (runner *SampleTestRunner) testEcho(ctx context.Context, t *testing.T, index int, testCase map[string]interface{}, variables map[string]interface{}, compareFunc *func(expectedResponse, response interface{}) error) {
+	steps := testCase[stepsJSONKey].([]interface{})
+	match, err := matchMode(testCase, runner.Match)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	timeout, maxLatency, err := callLimits(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	stepTimeout, err := durationField(testCase, stepTimeoutJSONKey)
+	if err != nil {
+		t.Fatalf("case #%d: %v", index, err)
+	}
+	if _, ok := testCase[stepTimeoutJSONKey]; !ok {
+		stepTimeout = defaultStepTimeout
+	}
+
+	schedule, err := newSchedule(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	reporter := newCaseReporter(t, testCase, runner.ContinueOnFailure)
+	defer reporter.summarize()
+	for i := 1; schedule.next(i); i++ {
+		var header, trailer metadata.MD
+		callCtx, cancel := callContext(ctx, timeout)
+		start := time.Now()
+		responses, err := runner.runEcho(callCtx, index, steps, stepTimeout, match, compareFunc, &header, &trailer)
+		latency := time.Since(start)
+		cancel()
+		var failures []error
+		if err != nil {
+			failures = append(failures, err)
+		}
+		failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+		if len(failures) == 0 {
+			if err := captureVariables(testCase, variables, index, nil, responses...); err != nil {
+				failures = append(failures, err)
+			}
+		}
+
+		if !reportIteration(reporter, schedule, i, failures) {
+			break
+		}
+	}
+}
+
+// runEcho opens a stream of Echo and runs the steps of the script in order over it.
+// Each step must finish within stepTimeout. index is the index of the test case in the scenario.
+// The responses are compared according to match unless compareFunc is given, and all the received responses are returned.
+// header and trailer are set when the end of the stream is received by the expect_eof step.
+func (runner *SampleTestRunner) runEcho(ctx context.Context, index int, steps []interface{}, stepTimeout time.Duration, match string, compareFunc *func(expectedResponse, response interface{}) error, header, trailer *metadata.MD) ([]proto.Message, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := runner.Client.Echo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the stream of Echo: %s", describeError(err))
+	}
 
-			switch successRule {
-			case successRuleAll:
+	compare := func(expectedValue interface{}, expectedRes, res *EchoResponse) error {
+		if compareFunc != nil {
+			compare := *compareFunc
+			return compare(expectedRes, res)
+		}
+		if diff := diffResponses(match, expectedValue, expectedRes, res); diff != "" {
+			return fmt.Errorf("the actual response of the Echo was not equal to the expected response (-expected +actual):\n%s", diff)
+		}
+		return nil
+	}
+	var responses []proto.Message
+	recv := func() (*EchoResponse, error) {
+		var res *EchoResponse
+		err := runner.withTimeout(stepTimeout, cancel, func() error {
+			var err error
+			res, err = stream.Recv()
+			return err
+		})
+		if err == nil {
+			responses = append(responses, res)
+		}
+		return res, err
+	}
+	recvExpected := func() (*EchoResponse, error) {
+		res, err := recv()
+		if err == io.EOF {
+			return nil, errors.New("the stream was closed before the expected response was received")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive the response: %s", describeError(err))
+		}
+		return res, nil
+	}
+	for j, s := range steps {
+		step := s.(map[string]interface{})
+		if len(step) != 1 {
+			return nil, fmt.Errorf("step #%d of Echo must have exactly one of %q, %q, %q, %q and %q", j, stepSend, stepExpect, stepExpectAnyOrder, stepCloseSend, stepExpectEOF)
+		}
+		for kind, value := range step {
+			var err error
+			switch kind {
+			case stepSend:
+				req := EchoRequest{}
+				if err := decodeMessage(value, &req, index, fmt.Sprintf("%s[%d].%s", stepsJSONKey, j, kind)); err != nil {
+					return nil, err
+				}
+				err = runner.withTimeout(stepTimeout, cancel, func() error {
+					return stream.Send(&req)
+				})
+				if err == io.EOF {
+					// io.EOF means that the server has closed the stream.
+					// The status is returned from Recv.
+					_, err = stream.Recv()
+					err = fmt.Errorf("the stream was closed by the server: %s", describeError(err))
+				}
+			case stepExpect:
+				expectedRes := EchoResponse{}
+				if err := decodeExpectedMessage(value, &expectedRes, index, fmt.Sprintf("%s[%d].%s", stepsJSONKey, j, kind)); err != nil {
+					return nil, err
+				}
+				var res *EchoResponse
+				if res, err = recvExpected(); err == nil {
+					err = compare(value, &expectedRes, res)
+				}
+			case stepExpectAnyOrder:
+				values, _ := value.([]interface{})
+				expectedResponses := make([]EchoResponse, len(values))
+				for k, v := range values {
+					if err := decodeExpectedMessage(v, &expectedResponses[k], index, fmt.Sprintf("%s[%d].%s[%d]", stepsJSONKey, j, kind, k)); err != nil {
+						return nil, err
+					}
+				}
+				var responses []*EchoResponse
+				for range expectedResponses {
+					var res *EchoResponse
+					if res, err = recvExpected(); err != nil {
+						break
+					}
+					responses = append(responses, res)
+				}
 				if err != nil {
-					t.Fatal(err.Error())
+					break
 				}
-			case successRuleOnce:
-				if i == loop && err != nil {
-					t.Fatal(err.Error())
+				matched := make([]bool, len(responses))
+			EXPECTED_LABEL:
+				for k := range expectedResponses {
+					for l, res := range responses {
+						if !matched[l] && compare(values[k], &expectedResponses[k], res) == nil {
+							matched[l] = true
+							continue EXPECTED_LABEL
+						}
+					}
+					err = fmt.Errorf("the expected response #%d was not found in the actual responses", k)
+					break
 				}
-				if err == nil {
-					break FOR_LABEL
+			case stepCloseSend:
+				err = stream.CloseSend()
+			case stepExpectEOF:
+				_, err = recv()
+				if err == io.EOF {
+					*header, err = stream.Header()
+					*trailer = stream.Trailer()
+				} else if err == nil {
+					err = errors.New("a response was received while the end of the stream was expected")
+				} else {
+					err = fmt.Errorf("the stream was terminated with an error: %s", describeError(err))
 				}
+			default:
+				err = fmt.Errorf("unknown step %q", kind)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("step #%d (%s) of Echo failed: %v", j, kind, err)
 			}
 		}
 	}
+	return responses, nil
 }
 
+func (runner *SampleTestRunner) testProfile(ctx context.Context, t *testing.T, index int, testCase map[string]interface{}, variables map[string]interface{}, compareFunc *func(expectedResponse, response interface{}) error) {
+	req := ProfileRequest{}
+	if err := decodeMessage(testCase[requestJSONKey], &req, index, requestJSONKey); err != nil {
+		t.Fatal(err.Error())
+	}
+	expectedRes := ProfileResponse{}
+	if err := decodeExpectedMessage(testCase[expectedResponseJSONKey], &expectedRes, index, expectedResponseJSONKey); err != nil {
+		t.Fatal(err.Error())
+	}
+	match, err := matchMode(testCase, runner.Match)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	timeout, maxLatency, err := callLimits(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	schedule, err := newSchedule(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	reporter := newCaseReporter(t, testCase, runner.ContinueOnFailure)
+	defer reporter.summarize()
+	for i := 1; schedule.next(i); i++ {
+		var header, trailer metadata.MD
+		callCtx, cancel := callContext(ctx, timeout)
+		start := time.Now()
+		res, err := runner.Client.Profile(callCtx, &req, grpc.Header(&header), grpc.Trailer(&trailer))
+		latency := time.Since(start)
+		cancel()
+
+		errExpectation := false
+		if v, ok := testCase[errorExpectationJSONKey]; ok {
+			errExpectation = v.(bool)
+		}
+		var failures []error
+		if errExpectation {
+			if mismatch := checkError(testCase, index, match, err); mismatch != nil {
+				failures = append(failures, fmt.Errorf("the error of the response of Profile is not as expected: %v", mismatch))
+			}
+			failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+			if len(failures) == 0 {
+				if err := captureVariables(testCase, variables, index, err, res); err != nil {
+					failures = append(failures, err)
+				}
+			}
+		} else {
+			if err != nil {
+				err = fmt.Errorf("the response of Profile was an unexpected error: %s", describeError(err))
+			} else if compareFunc != nil {
+				compare := *compareFunc
+				err = compare(&expectedRes, res)
+			} else if diff := diffResponses(match, testCase[expectedResponseJSONKey], &expectedRes, res); diff != "" {
+				err = fmt.Errorf("the actual response of the Profile was not equal to the expected response (-expected +actual):\n%s", diff)
+			}
+			if err != nil {
+				failures = append(failures, err)
+			}
+			failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+			if len(failures) == 0 {
+				if err := captureVariables(testCase, variables, index, nil, res); err != nil {
+					failures = append(failures, err)
+				}
+			}
+		}
+
+		if !reportIteration(reporter, schedule, i, failures) {
+			break
+		}
+	}
+}
+
+func (runner *SampleTestRunner) testWait(ctx context.Context, t *testing.T, index int, testCase map[string]interface{}, variables map[string]interface{}, compareFunc *func(expectedResponse, response interface{}) error) {
+	req := WaitRequest{}
+	if err := decodeMessage(testCase[requestJSONKey], &req, index, requestJSONKey); err != nil {
+		t.Fatal(err.Error())
+	}
+	expectedRes := WaitResponse{}
+	if err := decodeExpectedMessage(testCase[expectedResponseJSONKey], &expectedRes, index, expectedResponseJSONKey); err != nil {
+		t.Fatal(err.Error())
+	}
+	match, err := matchMode(testCase, runner.Match)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	timeout, maxLatency, err := callLimits(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	schedule, err := newSchedule(testCase, index)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	reporter := newCaseReporter(t, testCase, runner.ContinueOnFailure)
+	defer reporter.summarize()
+	for i := 1; schedule.next(i); i++ {
+		var header, trailer metadata.MD
+		callCtx, cancel := callContext(ctx, timeout)
+		start := time.Now()
+		res, err := runner.Client.Wait(callCtx, &req, grpc.Header(&header), grpc.Trailer(&trailer))
+		latency := time.Since(start)
+		cancel()
+
+		errExpectation := false
+		if v, ok := testCase[errorExpectationJSONKey]; ok {
+			errExpectation = v.(bool)
+		}
+		var failures []error
+		if errExpectation {
+			if mismatch := checkError(testCase, index, match, err); mismatch != nil {
+				failures = append(failures, fmt.Errorf("the error of the response of Wait is not as expected: %v", mismatch))
+			}
+			failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+			if len(failures) == 0 {
+				if err := captureVariables(testCase, variables, index, err, res); err != nil {
+					failures = append(failures, err)
+				}
+			}
+		} else {
+			if err != nil {
+				err = fmt.Errorf("the response of Wait was an unexpected error: %s", describeError(err))
+			} else if compareFunc != nil {
+				compare := *compareFunc
+				err = compare(&expectedRes, res)
+			} else if diff := diffResponses(match, testCase[expectedResponseJSONKey], &expectedRes, res); diff != "" {
+				err = fmt.Errorf("the actual response of the Wait was not equal to the expected response (-expected +actual):\n%s", diff)
+			}
+			if err != nil {
+				failures = append(failures, err)
+			}
+			failures = append(failures, checkCall(testCase, latency, maxLatency, header, trailer)...)
+			if len(failures) == 0 {
+				if err := captureVariables(testCase, variables, index, nil, res); err != nil {
+					failures = append(failures, err)
+				}
+			}
+		}
+
+		if !reportIteration(reporter, schedule, i, failures) {
+			break
+		}
+	}
+}
+
+// withTimeout runs f and returns an error if f does not return within timeout.
+// cancel is called on timeout so that the blocked call of the stream returns.
+func (runner *SampleTestRunner) withTimeout(timeout time.Duration, cancel context.CancelFunc, f func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		cancel()
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+}
@@ -0,0 +1,2554 @@
+package pb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	actionJSONKey               = "action"
+	requestJSONKey              = "request"
+	expectedResponseJSONKey     = "expected_response"
+	errorExpectationJSONKey     = "error_expectation"
+	expectedErrorCodeJSONKey    = "expected_error_code"
+	expectedErrorMessageJSONKey = "expected_error_message"
+	expectedErrorDetailsJSONKey = "expected_error_details"
+	loopJSONKey                 = "loop"
+	sleepJSONKey                = "sleep"
+	successRuleJSONKey          = "success_rule"
+	successRuleAll              = "all"
+	successRuleOnce             = "once"
+	expectedResponsesJSONKey    = "expected_responses"
+	responseOrderJSONKey        = "response_order"
+	responseOrderOrdered        = "ordered"
+	responseOrderUnordered      = "unordered"
+	requestsJSONKey             = "requests"
+	stepsJSONKey                = "steps"
+	stepTimeoutJSONKey          = "step_timeout"
+	stepSend                    = "send"
+	stepExpect                  = "expect"
+	stepExpectAnyOrder          = "expect_any_order"
+	stepCloseSend               = "close_send"
+	stepExpectEOF               = "expect_eof"
+	defaultStepTimeout          = 10 * time.Second
+	matchJSONKey                = "match"
+	matchExact                  = "exact"
+	matchPartial                = "partial"
+	matcherRegex                = "$regex"
+	matcherGt                   = "$gt"
+	matcherGte                  = "$gte"
+	matcherLt                   = "$lt"
+	matcherLte                  = "$lte"
+	matcherAny                  = "$any"
+	matcherNotEmpty             = "$not_empty"
+	matcherLen                  = "$len"
+	matcherContains             = "$contains"
+	matcherWithin               = "$within"
+	captureJSONKey              = "capture"
+	captureResponse             = "response"
+	captureResponses            = "responses"
+	captureError                = "error"
+	casesJSONKey                = "cases"
+	metadataJSONKey             = "metadata"
+	expectedHeaderJSONKey       = "expected_header"
+	expectedTrailerJSONKey      = "expected_trailer"
+	timeoutJSONKey              = "timeout"
+	maxLatencyJSONKey           = "max_latency"
+	nameJSONKey                 = "name"
+	skipJSONKey                 = "skip"
+	onlyJSONKey                 = "only"
+	tagsJSONKey                 = "tags"
+	tagsEnvironmentVariable     = "STEST_TAGS"
+	intervalJSONKey             = "interval"
+	eventuallyJSONKey           = "eventually"
+	defaultEventuallyInterval   = 100 * time.Millisecond
+	maxEventuallyInterval       = 5 * time.Second
+	continueOnFailureJSONKey    = "continue_on_failure"
+)
+
+// variablePattern matches a reference to a variable such as ${order_id}, or an escaped one such as $${order_id}.
+var variablePattern = regexp.MustCompile("\\$(\\$?)\\{([A-Za-z_][A-Za-z0-9_]*)\\}")
+
+// variableNamePattern matches a name of a variable.
+var variableNamePattern = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// capturePathPattern matches a step of the path of a capture, which is a field name such as ".id",
+// an index of a repeated field such as "[0]", or a quoted key of a map such as "[\"team\"]".
+var capturePathPattern = regexp.MustCompile("^(?:(\\.?)([A-Za-z_][A-Za-z0-9_]*)|\\[([0-9]+)\\]|\\[(\"(?:[^\"\\\\]|\\\\.)*\")\\])")
+
+// the kinds of the methods, which decide the keys accepted by the test cases.
+const (
+	methodUnary           = "unary"
+	methodServerStreaming = "server streaming"
+	methodClientStreaming = "client streaming"
+	methodBidiStreaming   = "bidirectional streaming"
+)
+
+// serviceMethod is a method of the service, which the actions of the test cases refer to.
+type serviceMethod struct {
+	// kind is the kind of the method, which decides the keys accepted by the test cases.
+	kind string
+	// request and response are the empty messages of the types of the request and the response.
+	request, response proto.Message
+}
+
+// testCaseField is a key of a test case.
+type testCaseField struct {
+	// methods are the kinds of the methods whose test cases accept the key. All the test cases accept it if empty.
+	methods []string
+	// validate reports the errors of the value to the validator.
+	validate func(v *scenarioValidator, path []interface{}, value interface{})
+}
+
+// accepts reports whether the test cases of the kind of methods accept the key.
+func (field testCaseField) accepts(method string) bool {
+	if len(field.methods) == 0 {
+		return true
+	}
+	for _, m := range field.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// errorFieldMethods are the kinds of the methods which return an error with a single status.
+var errorFieldMethods = []string{methodUnary, methodServerStreaming, methodClientStreaming}
+
+// testCaseFields has the keys which the test cases can have.
+var testCaseFields = map[string]testCaseField{
+	actionJSONKey:               {validate: validateString},
+	requestJSONKey:              {methods: []string{methodUnary, methodServerStreaming}, validate: validateObject},
+	expectedResponseJSONKey:     {methods: []string{methodUnary, methodClientStreaming}, validate: validateObject},
+	errorExpectationJSONKey:     {methods: errorFieldMethods, validate: validateBool},
+	expectedErrorCodeJSONKey:    {methods: errorFieldMethods, validate: validateErrorCode},
+	expectedErrorMessageJSONKey: {methods: errorFieldMethods, validate: validateStringMatcher},
+	expectedErrorDetailsJSONKey: {methods: errorFieldMethods, validate: validateErrorDetails},
+	loopJSONKey:                 {validate: validatePositiveInteger},
+	sleepJSONKey:                {validate: validateDuration},
+	successRuleJSONKey:          {validate: validateEnum(successRuleAll, successRuleOnce)},
+	expectedResponsesJSONKey:    {methods: []string{methodServerStreaming}, validate: validateObjects},
+	responseOrderJSONKey:        {methods: []string{methodServerStreaming}, validate: validateEnum(responseOrderOrdered, responseOrderUnordered)},
+	requestsJSONKey:             {methods: []string{methodClientStreaming}, validate: validateRequests},
+	stepsJSONKey:                {methods: []string{methodBidiStreaming}, validate: validateSteps},
+	stepTimeoutJSONKey:          {methods: []string{methodBidiStreaming}, validate: validateDuration},
+	matchJSONKey:                {validate: validateEnum(matchExact, matchPartial)},
+	captureJSONKey:              {validate: validateCapture},
+	metadataJSONKey:             {validate: validateMetadata},
+	expectedHeaderJSONKey:       {validate: validateExpectedMetadata},
+	expectedTrailerJSONKey:      {validate: validateExpectedMetadata},
+	timeoutJSONKey:              {validate: validateDuration},
+	maxLatencyJSONKey:           {validate: validateDuration},
+	nameJSONKey:                 {validate: validateName},
+	skipJSONKey:                 {validate: validateSkip},
+	onlyJSONKey:                 {validate: validateBool},
+	tagsJSONKey:                 {validate: validateTags},
+	continueOnFailureJSONKey:    {validate: validateBool},
+	intervalJSONKey:             {validate: validateDuration},
+	eventuallyJSONKey:           {validate: validateDuration},
+}
+
+// requiredTestCaseFields has the keys which the test cases of the kinds of methods must have.
+var requiredTestCaseFields = map[string][]string{
+	methodClientStreaming: {requestsJSONKey},
+	methodBidiStreaming:   {stepsJSONKey},
+}
+
+// loadScenario reads the scenario from the JSON file and validates all the test cases before any of them runs.
+// methods maps the names of the methods of the service to their kinds, which decide the keys accepted by the test cases,
+// and the types of their messages, into which the messages without references to variables are decoded.
+// The scenario is either an array of the test cases, or an object which has the array in "cases" and the defaults of
+// the test cases in the other keys. A default is applied to the test cases without the key whose methods accept it,
+// except that the default metadata is merged with the metadata of each test case.
+// The errors are reported with their locations such as "scenario.json:3:5".
+func loadScenario(jsonPath string, methods map[string]serviceMethod) ([]map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(jsonPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the scenario: %v", err)
+	}
+	v := &scenarioValidator{jsonPath: jsonPath, data: data, offsets: map[string]int{}}
+	scenario, err := v.decode()
+	if err != nil {
+		return nil, err
+	}
+	testCases := v.validate(scenario, methods)
+	if len(v.errors) > 0 {
+		sort.SliceStable(v.errors, func(i, j int) bool {
+			if v.errors[i].offset != v.errors[j].offset {
+				return v.errors[i].offset < v.errors[j].offset
+			}
+			return v.errors[i].message < v.errors[j].message
+		})
+		var messages []string
+		for i, e := range v.errors {
+			// A default is validated with each test case which it is applied to, but reported once.
+			if i > 0 && e == v.errors[i-1] {
+				continue
+			}
+			messages = append(messages, v.location(e.offset)+": "+e.message)
+		}
+		return nil, fmt.Errorf("the scenario is invalid:\n%s", strings.Join(messages, "\n"))
+	}
+	return testCases, nil
+}
+
+// scenarioValidator validates the scenario decoded from data, and collects the errors with their offsets.
+type scenarioValidator struct {
+	jsonPath string
+	data     []byte
+	// offsets maps the paths of the values in the scenario to their offsets in data.
+	offsets map[string]int
+	errors  []scenarioError
+}
+
+// scenarioError is an error of the value at offset in the scenario.
+type scenarioError struct {
+	offset  int
+	message string
+}
+
+// decode decodes the JSON, recording the offsets of the values.
+// Unlike json.Unmarshal, it rejects an object which has the same key twice.
+func (v *scenarioValidator) decode() (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(v.data))
+	var offset int
+	var decodeValue func(path []interface{}) (interface{}, error)
+	decodeValue = func(path []interface{}) (interface{}, error) {
+		offset = v.skip(int(decoder.InputOffset()))
+		v.offsets[formatPath(path)] = offset
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch token {
+		case json.Delim('{'):
+			object := map[string]interface{}{}
+			for decoder.More() {
+				offset = v.skip(int(decoder.InputOffset()))
+				token, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				key := token.(string)
+				if _, ok := object[key]; ok {
+					return nil, fmt.Errorf("duplicate key %q", key)
+				}
+				value, err := decodeValue(append(append([]interface{}{}, path...), key))
+				if err != nil {
+					return nil, err
+				}
+				object[key] = value
+			}
+			_, err = decoder.Token()
+			return object, err
+		case json.Delim('['):
+			array := []interface{}{}
+			for decoder.More() {
+				value, err := decodeValue(append(append([]interface{}{}, path...), len(array)))
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, value)
+			}
+			_, err = decoder.Token()
+			return array, err
+		}
+		return token, nil
+	}
+	scenario, err := decodeValue(nil)
+	if err == nil {
+		offset = v.skip(int(decoder.InputOffset()))
+		if _, err = decoder.Token(); err == io.EOF {
+			return scenario, nil
+		} else if err == nil {
+			err = errors.New("invalid data after the scenario")
+		}
+	}
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// The offset of a syntax error is after the invalid character.
+		offset = int(syntaxErr.Offset) - 1
+	case err == io.ErrUnexpectedEOF:
+		offset = len(v.data)
+	}
+	return nil, fmt.Errorf("%s: %v", v.location(offset), err)
+}
+
+// skip returns the offset of the next token after offset, skipping the white spaces and separators.
+func (v *scenarioValidator) skip(offset int) int {
+	for offset < len(v.data) && strings.IndexByte(" \t\r\n,:", v.data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// location returns the location of the offset in the file such as "scenario.json:3:5".
+func (v *scenarioValidator) location(offset int) string {
+	line, column := 1, 1
+	for _, b := range v.data[:offset] {
+		if b == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	return fmt.Sprintf("%s:%d:%d", v.jsonPath, line, column)
+}
+
+// errorf reports an error of the value at path.
+func (v *scenarioValidator) errorf(path []interface{}, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if p := formatPath(path); p != "" {
+		message = p + ": " + message
+	}
+	v.errors = append(v.errors, scenarioError{offset: v.offsets[formatPath(path)], message: message})
+}
+
+// validate validates the scenario, and returns the test cases with the defaults applied.
+func (v *scenarioValidator) validate(scenario interface{}, methods map[string]serviceMethod) []map[string]interface{} {
+	var casesPath []interface{}
+	defaults := map[string]interface{}{}
+	values, ok := scenario.([]interface{})
+	if object, isObject := scenario.(map[string]interface{}); isObject {
+		casesPath = []interface{}{casesJSONKey}
+		values, ok = object[casesJSONKey].([]interface{})
+		for _, key := range sortedKeys(object) {
+			if key == casesJSONKey {
+				continue
+			}
+			path := []interface{}{key}
+			field, known := testCaseFields[key]
+			switch {
+			case !known:
+				v.errorf(path, "unknown key")
+			case key == actionJSONKey || key == nameJSONKey || key == onlyJSONKey:
+				v.errorf(path, "%s cannot have a default", key)
+			default:
+				v.validateField(field, path, object[key])
+				defaults[key] = object[key]
+			}
+		}
+	}
+	if !ok {
+		v.errorf(casesPath, "the scenario must be an array of the test cases or an object which has %q", casesJSONKey)
+		return nil
+	}
+	testCases := make([]map[string]interface{}, len(values))
+	names := map[string]bool{}
+	for i, value := range values {
+		path := append(append([]interface{}{}, casesPath...), i)
+		testCase, ok := value.(map[string]interface{})
+		if !ok {
+			v.errorf(path, "the test case must be an object")
+			continue
+		}
+		method := v.validateTestCase(path, testCase, methods)
+		if name, ok := testCase[nameJSONKey].(string); ok {
+			if names[name] {
+				v.errorf(append(path, nameJSONKey), "duplicate name %q", name)
+			}
+			names[name] = true
+		}
+		// paths are the paths of the keys of the test case, which are the paths of the defaults if they are applied.
+		paths := map[string][]interface{}{}
+		for key := range testCase {
+			paths[key] = append(append([]interface{}{}, path...), key)
+		}
+		for key, defaultValue := range defaults {
+			value, ok := testCase[key]
+			switch {
+			case !testCaseFields[key].accepts(method):
+			case !ok:
+				testCase[key] = defaultValue
+				paths[key] = []interface{}{key}
+			case key == metadataJSONKey:
+				defaultEntries, _ := defaultValue.(map[string]interface{})
+				entries, _ := value.(map[string]interface{})
+				merged := make(map[string]interface{}, len(defaultEntries)+len(entries))
+				for k, v := range defaultEntries {
+					merged[k] = v
+				}
+				for k, v := range entries {
+					merged[k] = v
+				}
+				testCase[key] = merged
+			}
+		}
+		// The conflicts are checked after the defaults are applied, because either of them may be a default.
+		if _, ok := testCase[eventuallyJSONKey]; ok {
+			for _, key := range []string{loopJSONKey, successRuleJSONKey} {
+				if _, ok := testCase[key]; ok {
+					v.errorf(paths[key], "cannot be used with %s", eventuallyJSONKey)
+				}
+			}
+		}
+		if action, ok := testCase[actionJSONKey].(string); ok {
+			if m, ok := methods[action]; ok {
+				v.validateMessages(paths, testCase, m)
+			}
+		}
+		testCases[i] = testCase
+	}
+	return testCases
+}
+
+// validateTestCase validates the test case at path, and returns the kind of the method of its action.
+func (v *scenarioValidator) validateTestCase(path []interface{}, testCase map[string]interface{}, methods map[string]serviceMethod) string {
+	actionPath := append(append([]interface{}{}, path...), actionJSONKey)
+	action, ok := testCase[actionJSONKey].(string)
+	m, known := methods[action]
+	method := m.kind
+	switch {
+	case testCase[actionJSONKey] == nil:
+		v.errorf(path, "%s is required", actionJSONKey)
+	case !ok:
+		v.errorf(actionPath, "must be a string")
+	case !known:
+		v.errorf(actionPath, "%v", unknownActionError(action, methods))
+	}
+	for _, key := range sortedKeys(testCase) {
+		keyPath := append(append([]interface{}{}, path...), key)
+		field, ok := testCaseFields[key]
+		switch {
+		case !ok:
+			v.errorf(keyPath, "unknown key")
+		case known && !field.accepts(method):
+			v.errorf(keyPath, "%s is a %s method, whose test cases do not accept %s", action, method, key)
+		case key != actionJSONKey:
+			v.validateField(field, keyPath, testCase[key])
+		}
+	}
+	for _, key := range requiredTestCaseFields[method] {
+		if _, ok := testCase[key]; !ok {
+			v.errorf(path, "%s is required for %s, which is a %s method", key, action, method)
+		}
+	}
+	return method
+}
+
+// unknownActionError returns the error of the action which is not a method of the service.
+// If the action looks like a typo of a method, the method is suggested.
+func unknownActionError(action string, methods map[string]serviceMethod) error {
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	suggestion, minDistance := "", -1
+	for _, name := range names {
+		distance := editDistance(strings.ToLower(action), strings.ToLower(name))
+		if distance <= (utf8.RuneCountInString(name)+2)/3 && (minDistance < 0 || distance < minDistance) {
+			suggestion, minDistance = name, distance
+		}
+	}
+	if suggestion != "" {
+		return fmt.Errorf("unknown action %q, did you mean %q? The methods of the service are %s", action, suggestion, strings.Join(names, ", "))
+	}
+	return fmt.Errorf("unknown action %q. The methods of the service are %s", action, strings.Join(names, ", "))
+}
+
+// editDistance returns the Levenshtein distance between the strings.
+func editDistance(a, b string) int {
+	s, u := []rune(a), []rune(b)
+	distances := make([]int, len(u)+1)
+	for j := range distances {
+		distances[j] = j
+	}
+	for i := range s {
+		previous := distances[0]
+		distances[0] = i + 1
+		for j := range u {
+			cost := 1
+			if s[i] == u[j] {
+				cost = 0
+			}
+			distance := previous + cost
+			if d := distances[j+1] + 1; d < distance {
+				distance = d
+			}
+			if d := distances[j] + 1; d < distance {
+				distance = d
+			}
+			previous, distances[j+1] = distances[j+1], distance
+		}
+	}
+	return distances[len(u)]
+}
+
+// validateMessages decodes the messages of the test case with the defaults applied into the messages of the method,
+// so that the unknown fields and the values of wrong types are reported before any request is sent.
+// paths are the paths of the keys of the test case. The messages which refer to variables are decoded when the test case runs.
+func (v *scenarioValidator) validateMessages(paths map[string][]interface{}, testCase map[string]interface{}, m serviceMethod) {
+	elementPath := func(path []interface{}, elements ...interface{}) []interface{} {
+		return append(append([]interface{}{}, path...), elements...)
+	}
+	switch m.kind {
+	case methodUnary:
+		v.validateMessage(paths[requestJSONKey], testCase[requestJSONKey], m.request, false)
+		v.validateMessage(paths[expectedResponseJSONKey], testCase[expectedResponseJSONKey], m.response, true)
+	case methodServerStreaming:
+		v.validateMessage(paths[requestJSONKey], testCase[requestJSONKey], m.request, false)
+		expectedResponses, _ := testCase[expectedResponsesJSONKey].([]interface{})
+		for i, expectedResponse := range expectedResponses {
+			v.validateMessage(elementPath(paths[expectedResponsesJSONKey], i), expectedResponse, m.response, true)
+		}
+	case methodClientStreaming:
+		requests, _ := testCase[requestsJSONKey].([]interface{})
+		for i, request := range requests {
+			if object, ok := request.(map[string]interface{}); ok {
+				v.validateMessage(elementPath(paths[requestsJSONKey], i, requestJSONKey), object[requestJSONKey], m.request, false)
+			}
+		}
+		v.validateMessage(paths[expectedResponseJSONKey], testCase[expectedResponseJSONKey], m.response, true)
+	case methodBidiStreaming:
+		steps, _ := testCase[stepsJSONKey].([]interface{})
+		for i, step := range steps {
+			object, _ := step.(map[string]interface{})
+			v.validateMessage(elementPath(paths[stepsJSONKey], i, stepSend), object[stepSend], m.request, false)
+			v.validateMessage(elementPath(paths[stepsJSONKey], i, stepExpect), object[stepExpect], m.response, true)
+			expectedResponses, _ := object[stepExpectAnyOrder].([]interface{})
+			for j, expectedResponse := range expectedResponses {
+				v.validateMessage(elementPath(paths[stepsJSONKey], i, stepExpectAnyOrder, j), expectedResponse, m.response, true)
+			}
+		}
+	}
+}
+
+// validateMessage decodes the value at path into a new message of the type of message in the same way as decodeMessage,
+// or decodeExpectedMessage if expected is true. The values which are not objects or refer to variables are not decoded.
+func (v *scenarioValidator) validateMessage(path []interface{}, value interface{}, message proto.Message, expected bool) {
+	if _, ok := value.(map[string]interface{}); !ok {
+		return
+	}
+	if expected {
+		errors := len(v.errors)
+		v.validateMatchers(path, value)
+		if len(v.errors) > errors {
+			return
+		}
+	}
+	if hasVariables(value) {
+		return
+	}
+	if expected {
+		var matchers []fieldMatcher
+		value, _ = extractMatchers(value, nil, &matchers)
+	}
+	messageJSON, err := json.Marshal(value)
+	if err == nil {
+		err = protojson.Unmarshal(messageJSON, message.ProtoReflect().New().Interface())
+	}
+	if err != nil {
+		v.errorf(path, "failed to decode the message: %v", err)
+	}
+}
+
+// validateMatchers validates the matchers in the value at path, and reports the errors at the locations of the conditions.
+// The conditions which refer to variables are validated when the test case runs.
+func (v *scenarioValidator) validateMatchers(path []interface{}, value interface{}) {
+	if conditions, ok := matcherConditions(value); ok {
+		for _, name := range sortedKeys(conditions) {
+			if hasVariables(conditions[name]) {
+				continue
+			}
+			if err := validateCondition(name, conditions[name]); err != nil {
+				v.errorf(append(append([]interface{}{}, path...), name), "%v", err)
+			}
+		}
+		return
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(value) {
+			v.validateMatchers(append(append([]interface{}{}, path...), key), value[key])
+		}
+	case []interface{}:
+		for i, element := range value {
+			elementPath := append(append([]interface{}{}, path...), i)
+			if _, ok := matcherConditions(element); ok {
+				v.errorf(elementPath, "a matcher cannot be an element of an array")
+				continue
+			}
+			v.validateMatchers(elementPath, element)
+		}
+	}
+}
+
+// hasVariables reports whether the value of the scenario has references to variables.
+func hasVariables(value interface{}) bool {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, element := range value {
+			if hasVariables(element) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, element := range value {
+			if hasVariables(element) {
+				return true
+			}
+		}
+	case string:
+		return variablePattern.MatchString(value)
+	}
+	return false
+}
+
+// validateField validates the value of the field unless it is a reference to a variable,
+// which has the value captured by a previous test case. Such a value is validated by checkExpandedTestCase
+// after the variable is expanded.
+func (v *scenarioValidator) validateField(field testCaseField, path []interface{}, value interface{}) {
+	if s, ok := value.(string); ok {
+		if m := variablePattern.FindStringSubmatch(s); m != nil && m[0] == s && m[1] == "" {
+			return
+		}
+	}
+	field.validate(v, path, value)
+}
+
+// sortedKeys returns the keys of the object in order, so that the errors are reported in a stable order.
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func validateString(v *scenarioValidator, path []interface{}, value interface{}) {
+	if _, ok := value.(string); !ok {
+		v.errorf(path, "must be a string")
+	}
+}
+
+func validateBool(v *scenarioValidator, path []interface{}, value interface{}) {
+	if _, ok := value.(bool); !ok {
+		v.errorf(path, "must be a boolean")
+	}
+}
+
+func validateObject(v *scenarioValidator, path []interface{}, value interface{}) {
+	if _, ok := value.(map[string]interface{}); !ok {
+		v.errorf(path, "must be an object")
+	}
+}
+
+func validateName(v *scenarioValidator, path []interface{}, value interface{}) {
+	if s, ok := value.(string); !ok || s == "" {
+		v.errorf(path, "must be a non-empty string")
+	}
+}
+
+// validateSkip validates that the value is a boolean or a reason to skip the test case.
+func validateSkip(v *scenarioValidator, path []interface{}, value interface{}) {
+	if s, ok := value.(string); ok && s != "" {
+		return
+	}
+	if _, ok := value.(bool); !ok {
+		v.errorf(path, "must be a boolean or a non-empty reason")
+	}
+}
+
+// validateTags validates that the value is an array of tags, which cannot have "," or start with "!".
+func validateTags(v *scenarioValidator, path []interface{}, value interface{}) {
+	tags, ok := value.([]interface{})
+	if !ok {
+		v.errorf(path, "must be an array of strings")
+		return
+	}
+	for i, tag := range tags {
+		if s, ok := tag.(string); !ok || s == "" || strings.Contains(s, ",") || strings.HasPrefix(s, "!") {
+			v.errorf(append(append([]interface{}{}, path...), i), "invalid tag %v", tag)
+		}
+	}
+}
+
+func validatePositiveInteger(v *scenarioValidator, path []interface{}, value interface{}) {
+	if n, ok := value.(float64); !ok || n < 1 || n != float64(int(n)) {
+		v.errorf(path, "must be a positive integer")
+	}
+}
+
+func validateDuration(v *scenarioValidator, path []interface{}, value interface{}) {
+	if _, err := parseDuration(value); err != nil {
+		v.errorf(path, "%v", err)
+	}
+}
+
+// validateEnum returns a function which validates that the value is one of values.
+func validateEnum(values ...string) func(v *scenarioValidator, path []interface{}, value interface{}) {
+	return func(v *scenarioValidator, path []interface{}, value interface{}) {
+		for _, s := range values {
+			if value == s {
+				return
+			}
+		}
+		v.errorf(path, "must be one of %q", values)
+	}
+}
+
+func validateObjects(v *scenarioValidator, path []interface{}, value interface{}) {
+	elements, ok := value.([]interface{})
+	if !ok {
+		v.errorf(path, "must be an array of objects")
+		return
+	}
+	for i, element := range elements {
+		validateObject(v, append(append([]interface{}{}, path...), i), element)
+	}
+}
+
+func validateErrorCode(v *scenarioValidator, path []interface{}, value interface{}) {
+	if _, err := parseCode(value); err != nil {
+		v.errorf(path, "%v", err)
+	}
+}
+
+// validateStringMatcher validates that the value is a string or a matcher.
+func validateStringMatcher(v *scenarioValidator, path []interface{}, value interface{}) {
+	if _, ok := value.(string); ok {
+		return
+	}
+	if _, ok := matcherConditions(value); !ok {
+		v.errorf(path, "must be a string or a matcher")
+		return
+	}
+	v.validateMatchers(path, value)
+}
+
+func validateErrorDetails(v *scenarioValidator, path []interface{}, value interface{}) {
+	details, ok := value.([]interface{})
+	if !ok {
+		v.errorf(path, "must be an array of objects")
+		return
+	}
+	for i, detail := range details {
+		detailPath := append(append([]interface{}{}, path...), i)
+		object, ok := detail.(map[string]interface{})
+		if !ok {
+			v.errorf(detailPath, "must be an object")
+			continue
+		}
+		if _, ok := object["@type"].(string); !ok {
+			v.errorf(detailPath, "must have @type")
+		}
+	}
+}
+
+// validateRequests validates the requests of a client streaming method, which are objects with request and sleep.
+func validateRequests(v *scenarioValidator, path []interface{}, value interface{}) {
+	requests, ok := value.([]interface{})
+	if !ok {
+		v.errorf(path, "must be an array of objects")
+		return
+	}
+	for i, request := range requests {
+		requestPath := append(append([]interface{}{}, path...), i)
+		object, ok := request.(map[string]interface{})
+		if !ok {
+			v.errorf(requestPath, "must be an object")
+			continue
+		}
+		for _, key := range sortedKeys(object) {
+			keyPath := append(append([]interface{}{}, requestPath...), key)
+			switch key {
+			case requestJSONKey:
+				validateObject(v, keyPath, object[key])
+			case sleepJSONKey:
+				validateDuration(v, keyPath, object[key])
+			default:
+				v.errorf(keyPath, "unknown key")
+			}
+		}
+	}
+}
+
+// validateSteps validates the steps of a bidirectional streaming method, each of which has exactly one key.
+func validateSteps(v *scenarioValidator, path []interface{}, value interface{}) {
+	steps, ok := value.([]interface{})
+	if !ok {
+		v.errorf(path, "must be an array of objects")
+		return
+	}
+	for i, step := range steps {
+		stepPath := append(append([]interface{}{}, path...), i)
+		object, ok := step.(map[string]interface{})
+		if !ok || len(object) != 1 {
+			v.errorf(stepPath, "must be an object which has exactly one of %q", []string{stepSend, stepExpect, stepExpectAnyOrder, stepCloseSend, stepExpectEOF})
+			continue
+		}
+		for key, value := range object {
+			keyPath := append(append([]interface{}{}, stepPath...), key)
+			switch key {
+			case stepSend, stepExpect:
+				validateObject(v, keyPath, value)
+			case stepExpectAnyOrder:
+				validateObjects(v, keyPath, value)
+			case stepCloseSend, stepExpectEOF:
+				if value != true {
+					v.errorf(keyPath, "must be true")
+				}
+			default:
+				v.errorf(keyPath, "unknown step")
+			}
+		}
+	}
+}
+
+// validateCapture validates the names of the variables and the paths of the values to capture.
+func validateCapture(v *scenarioValidator, path []interface{}, value interface{}) {
+	captures, ok := value.(map[string]interface{})
+	if !ok {
+		v.errorf(path, "must be an object")
+		return
+	}
+	for _, name := range sortedKeys(captures) {
+		namePath := append(append([]interface{}{}, path...), name)
+		if !variableNamePattern.MatchString(name) {
+			v.errorf(namePath, "invalid variable name")
+			continue
+		}
+		capturePath, ok := captures[name].(string)
+		if !ok {
+			v.errorf(namePath, "must be a string")
+			continue
+		}
+		steps, err := parseCapturePath(capturePath)
+		if err != nil {
+			v.errorf(namePath, "%v", err)
+			continue
+		}
+		switch steps[0] {
+		case captureResponse, captureResponses, captureError:
+		default:
+			v.errorf(namePath, "the path must start with %q, %q or %q", captureResponse, captureResponses+"[i]", captureError)
+		}
+	}
+}
+
+// validateMetadata validates the metadata, whose values are strings or arrays of strings.
+// The values of the binary keys must be encoded in base64 unless they have references to variables.
+func validateMetadata(v *scenarioValidator, path []interface{}, value interface{}) {
+	entries, ok := value.(map[string]interface{})
+	if !ok {
+		v.errorf(path, "must be an object")
+		return
+	}
+	for _, key := range sortedKeys(entries) {
+		keyPath := append(append([]interface{}{}, path...), key)
+		values, ok := entries[key].([]interface{})
+		if !ok {
+			values = []interface{}{entries[key]}
+		}
+		for _, element := range values {
+			s, ok := element.(string)
+			if !ok {
+				v.errorf(keyPath, "the values must be strings")
+				break
+			}
+			if isBinaryMetadataKey(key) && !variablePattern.MatchString(s) {
+				if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+					v.errorf(keyPath, "%v", err)
+					break
+				}
+			}
+		}
+	}
+}
+
+// validateExpectedMetadata validates the expected header or trailer, whose values are strings, arrays of strings or matchers.
+func validateExpectedMetadata(v *scenarioValidator, path []interface{}, value interface{}) {
+	entries, ok := value.(map[string]interface{})
+	if !ok {
+		v.errorf(path, "must be an object")
+		return
+	}
+	for _, key := range sortedKeys(entries) {
+		keyPath := append(append([]interface{}{}, path...), key)
+		if values, ok := entries[key].([]interface{}); ok {
+			for _, element := range values {
+				if _, ok := element.(string); !ok {
+					v.errorf(keyPath, "the values must be strings")
+					break
+				}
+			}
+			continue
+		}
+		validateStringMatcher(v, keyPath, entries[key])
+	}
+}
+
+// skipReasons returns the reasons to skip the test cases, which are empty for the test cases to run.
+// A test case is skipped if it has skip, if another test case has only, or if it is not selected by tags.
+// tags is a comma-separated list such as "smoke,!slow", which selects the test cases with any of the tags without "!"
+// and without all the tags with "!". If tags is empty, the environment variable STEST_TAGS is used.
+func skipReasons(testCases []map[string]interface{}, tags string) ([]string, error) {
+	if tags == "" {
+		tags = os.Getenv(tagsEnvironmentVariable)
+	}
+	var included, excluded []string
+	if tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			tag = strings.TrimSpace(tag)
+			switch {
+			case strings.HasPrefix(tag, "!") && len(tag) > 1:
+				excluded = append(excluded, tag[1:])
+			case tag != "" && !strings.HasPrefix(tag, "!"):
+				included = append(included, tag)
+			default:
+				return nil, fmt.Errorf("invalid tags %q", tags)
+			}
+		}
+	}
+	only := false
+	for _, testCase := range testCases {
+		if testCase[onlyJSONKey] == true {
+			only = true
+		}
+	}
+	reasons := make([]string, len(testCases))
+	for i, testCase := range testCases {
+		caseTags := map[string]bool{}
+		values, _ := testCase[tagsJSONKey].([]interface{})
+		for _, value := range values {
+			if tag, ok := value.(string); ok {
+				caseTags[tag] = true
+			}
+		}
+		selected := len(included) == 0
+		for _, tag := range included {
+			selected = selected || caseTags[tag]
+		}
+		for _, tag := range excluded {
+			selected = selected && !caseTags[tag]
+		}
+		switch skip := testCase[skipJSONKey].(type) {
+		case string:
+			reasons[i] = skip
+		case bool:
+			if skip {
+				reasons[i] = "skip is true"
+			}
+		}
+		switch {
+		case reasons[i] != "":
+		case only && testCase[onlyJSONKey] != true:
+			reasons[i] = "another test case has only"
+		case !selected:
+			reasons[i] = fmt.Sprintf("the tags %v are not selected by %q", values, tags)
+		}
+	}
+	return reasons, nil
+}
+
+// decodeMessage decodes the value of the scenario into the message with protojson,
+// which accepts both the proto field names and the JSON names of the fields.
+// A nil value, which means that the value is omitted in the scenario, leaves the message empty.
+// The error includes the index of the test case and path, which is the location of the value in the test case.
+func decodeMessage(value interface{}, message proto.Message, index int, path string) error {
+	if value == nil {
+		return nil
+	}
+	messageJSON, err := json.Marshal(value)
+	if err == nil {
+		err = protojson.Unmarshal(messageJSON, message)
+	}
+	if err != nil {
+		return fmt.Errorf("case #%d: %s: failed to decode the message: %v", index, path, err)
+	}
+	return nil
+}
+
+// decodeExpectedMessage decodes the expected response of the scenario in the same way as decodeMessage,
+// except that the fields written as matchers are validated and left unset in the message.
+func decodeExpectedMessage(value interface{}, message proto.Message, index int, path string) error {
+	var matchers []fieldMatcher
+	value, err := extractMatchers(value, nil, &matchers)
+	if err != nil {
+		return fmt.Errorf("case #%d: %s: %v", index, path, err)
+	}
+	return decodeMessage(value, message, index, path)
+}
+
+// diffMessages compares the messages with proto.Equal, and returns the diff of them by the fields
+// if they are not equal, or an empty string if they are equal.
+func diffMessages(expected, actual proto.Message) string {
+	if proto.Equal(expected, actual) {
+		return ""
+	}
+	return cmp.Diff(expected, actual, protocmp.Transform())
+}
+
+// matchMode returns the match mode of the test case, or defaultMatch if the test case does not have match.
+func matchMode(testCase map[string]interface{}, defaultMatch string) (string, error) {
+	match := defaultMatch
+	if v, ok := testCase[matchJSONKey]; ok {
+		match, _ = v.(string)
+	}
+	switch match {
+	case "":
+		return matchExact, nil
+	case matchExact, matchPartial:
+		return match, nil
+	}
+	return "", fmt.Errorf("%s must be %q or %q, but got %q", matchJSONKey, matchExact, matchPartial, match)
+}
+
+// diffResponses returns the diff of the expected response and the actual response according to match,
+// or an empty string if they match. expectedValue is the expected response written in the scenario.
+// If match is matchPartial, only the fields written in expectedValue are compared.
+// The fields written as matchers are checked by the matchers instead of being compared.
+func diffResponses(match string, expectedValue interface{}, expected, actual proto.Message) string {
+	var matchers []fieldMatcher
+	expectedValue, _ = extractMatchers(expectedValue, nil, &matchers)
+	var lines []string
+	if match == matchPartial {
+		diffMessagesPartially("", expectedValue, expected.ProtoReflect(), actual.ProtoReflect(), &lines)
+	} else {
+		// The fields written as matchers are unset in the expected response, so they are cleared in the actual response too.
+		unmatched := proto.Clone(actual)
+		for _, matcher := range matchers {
+			if target, err := resolveMatcherTarget(unmatched.ProtoReflect(), matcher.path); err == nil {
+				target.clear()
+			}
+		}
+		if diff := diffMessages(expected, unmatched); diff != "" {
+			lines = append(lines, strings.TrimSuffix(diff, "\n"))
+		}
+	}
+	for _, matcher := range matchers {
+		lines = append(lines, matcher.diff(actual.ProtoReflect())...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// diffMessagesPartially appends the lines of the diff of the fields written in expectedValue to lines.
+// Nested messages, maps and repeated fields are compared in the same way recursively.
+// The messages which are not written as JSON objects and the well-known types are compared entirely.
+func diffMessagesPartially(path string, expectedValue interface{}, expected, actual protoreflect.Message, lines *[]string) {
+	values, ok := expectedValue.(map[string]interface{})
+	if !ok || expected.Descriptor().FullName().Parent() == "google.protobuf" {
+		if !proto.Equal(expected.Interface(), actual.Interface()) {
+			*lines = append(*lines, diffLines(path, formatMessage(expected), formatMessage(actual))...)
+		}
+		return
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := expected.Descriptor().Fields()
+	for _, name := range names {
+		fd := fields.ByJSONName(name)
+		if fd == nil {
+			fd = fields.ByName(protoreflect.Name(name))
+		}
+		if fd == nil {
+			continue
+		}
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+		switch {
+		case fd.HasPresence() && expected.Has(fd) != actual.Has(fd):
+			*lines = append(*lines, diffLines(fieldPath, formatField(expected, fd), formatField(actual, fd))...)
+		case fd.IsList():
+			diffListsPartially(fieldPath, fd, values[name], expected.Get(fd).List(), actual.Get(fd).List(), lines)
+		case fd.IsMap():
+			diffMapsPartially(fieldPath, fd.MapValue(), values[name], expected.Get(fd).Map(), actual.Get(fd).Map(), lines)
+		case fd.Message() != nil:
+			diffMessagesPartially(fieldPath, values[name], expected.Get(fd).Message(), actual.Get(fd).Message(), lines)
+		case !expected.Get(fd).Equal(actual.Get(fd)):
+			*lines = append(*lines, diffLines(fieldPath, formatField(expected, fd), formatField(actual, fd))...)
+		}
+	}
+}
+
+// diffListsPartially appends the lines of the diff of the repeated field to lines.
+// The lists must have the same length, and the messages in them are compared partially.
+func diffListsPartially(path string, fd protoreflect.FieldDescriptor, expectedValue interface{}, expected, actual protoreflect.List, lines *[]string) {
+	if expected.Len() != actual.Len() {
+		*lines = append(*lines, diffLines(path, fmt.Sprintf("%d elements", expected.Len()), fmt.Sprintf("%d elements", actual.Len()))...)
+		return
+	}
+	values, _ := expectedValue.([]interface{})
+	for i := 0; i < expected.Len(); i++ {
+		elemPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case fd.Message() != nil && i < len(values):
+			diffMessagesPartially(elemPath, values[i], expected.Get(i).Message(), actual.Get(i).Message(), lines)
+		case !expected.Get(i).Equal(actual.Get(i)):
+			*lines = append(*lines, diffLines(elemPath, formatValue(fd, expected.Get(i)), formatValue(fd, actual.Get(i)))...)
+		}
+	}
+}
+
+// diffMapsPartially appends the lines of the diff of the entries in the expected map to lines.
+// The entries only in the actual map are ignored, and the messages in the entries are compared partially.
+// valueFd is the descriptor of the values of the map.
+func diffMapsPartially(path string, valueFd protoreflect.FieldDescriptor, expectedValue interface{}, expected, actual protoreflect.Map, lines *[]string) {
+	values, _ := expectedValue.(map[string]interface{})
+	var keys []protoreflect.MapKey
+	expected.Range(func(key protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, key)
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	for _, key := range keys {
+		entryPath := fmt.Sprintf("%s[%q]", path, key.String())
+		expectedEntry := expected.Get(key)
+		switch {
+		case !actual.Has(key):
+			*lines = append(*lines, diffLines(entryPath, formatValue(valueFd, expectedEntry), "(unset)")...)
+		case valueFd.Message() != nil:
+			diffMessagesPartially(entryPath, values[key.String()], expectedEntry.Message(), actual.Get(key).Message(), lines)
+		case !expectedEntry.Equal(actual.Get(key)):
+			*lines = append(*lines, diffLines(entryPath, formatValue(valueFd, expectedEntry), formatValue(valueFd, actual.Get(key)))...)
+		}
+	}
+}
+
+// diffLines returns the lines of the diff of the value at path in the same form as cmp.Diff.
+func diffLines(path, expected, actual string) []string {
+	if path == "" {
+		path = "(message)"
+	}
+	return []string{"- " + path + ": " + expected, "+ " + path + ": " + actual}
+}
+
+// formatField returns the string of the value of the field in the message, or "(unset)" if the field is not set.
+func formatField(m protoreflect.Message, fd protoreflect.FieldDescriptor) string {
+	if fd.HasPresence() && !m.Has(fd) {
+		return "(unset)"
+	}
+	return formatValue(fd, m.Get(fd))
+}
+
+// formatValue returns the string of the value of the field in the same form as protojson.
+func formatValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return formatMessage(v.Message())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.StringKind:
+		return strconv.Quote(v.String())
+	case protoreflect.BytesKind:
+		return strconv.Quote(base64.StdEncoding.EncodeToString(v.Bytes()))
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// formatMessage returns the message in the single-line protojson form.
+func formatMessage(m protoreflect.Message) string {
+	messageJSON, err := protojson.Marshal(m.Interface())
+	if err != nil {
+		return err.Error()
+	}
+	return string(messageJSON)
+}
+
+// fieldMatcher is a matcher written in the expected response instead of the value of a field or a map entry,
+// which is a JSON object whose keys start with "$" such as {"$regex": "^ord_"}.
+type fieldMatcher struct {
+	// path is the location of the matcher in the expected response, which consists of the keys of the JSON objects
+	// and the indexes of the JSON arrays.
+	path []interface{}
+	// conditions are the conditions of the matcher, all of which must be satisfied.
+	conditions map[string]interface{}
+}
+
+// extractMatchers returns the value without the matchers, and appends the matchers in the value to matchers.
+// path is the location of the value in the expected response.
+func extractMatchers(value interface{}, path []interface{}, matchers *[]fieldMatcher) (interface{}, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		extracted := make(map[string]interface{}, len(value))
+		for key, v := range value {
+			keyPath := append(append([]interface{}{}, path...), key)
+			if conditions, ok := matcherConditions(v); ok {
+				if err := validateMatcher(conditions); err != nil {
+					return nil, fmt.Errorf("%s: %v", formatPath(keyPath), err)
+				}
+				*matchers = append(*matchers, fieldMatcher{path: keyPath, conditions: conditions})
+				continue
+			}
+			v, err := extractMatchers(v, keyPath, matchers)
+			if err != nil {
+				return nil, err
+			}
+			extracted[key] = v
+		}
+		return extracted, nil
+	case []interface{}:
+		extracted := make([]interface{}, len(value))
+		for i, v := range value {
+			indexPath := append(append([]interface{}{}, path...), i)
+			if _, ok := matcherConditions(v); ok {
+				return nil, fmt.Errorf("%s: a matcher cannot be an element of an array", formatPath(indexPath))
+			}
+			v, err := extractMatchers(v, indexPath, matchers)
+			if err != nil {
+				return nil, err
+			}
+			extracted[i] = v
+		}
+		return extracted, nil
+	}
+	return value, nil
+}
+
+// matcherConditions returns the value as the conditions of a matcher if it is a JSON object whose keys start with "$".
+func matcherConditions(value interface{}) (map[string]interface{}, bool) {
+	conditions, ok := value.(map[string]interface{})
+	if !ok || len(conditions) == 0 {
+		return nil, false
+	}
+	for name := range conditions {
+		if !strings.HasPrefix(name, "$") {
+			return nil, false
+		}
+	}
+	return conditions, true
+}
+
+// validateMatcher returns an error if the conditions of a matcher have an unknown matcher or an invalid condition.
+func validateMatcher(conditions map[string]interface{}) error {
+	for _, name := range sortedKeys(conditions) {
+		if err := validateCondition(name, conditions[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateCondition returns an error if the matcher is unknown or the condition is not of the type of the matcher.
+// The condition of $contains can be any value, because it depends on the field.
+func validateCondition(name string, condition interface{}) error {
+	switch name {
+	case matcherRegex:
+		pattern, ok := condition.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", name)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+	case matcherGt, matcherGte, matcherLt, matcherLte:
+		if _, ok := condition.(float64); !ok {
+			return fmt.Errorf("%s must be a number", name)
+		}
+	case matcherLen:
+		if n, ok := condition.(float64); !ok || n < 0 || n != float64(int(n)) {
+			return fmt.Errorf("%s must be a non-negative integer", name)
+		}
+	case matcherAny, matcherNotEmpty:
+		if _, ok := condition.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", name)
+		}
+	case matcherWithin:
+		s, _ := condition.(string)
+		if _, err := time.ParseDuration(s); err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+	case matcherContains:
+	default:
+		return fmt.Errorf("unknown matcher %q", name)
+	}
+	return nil
+}
+
+// formatPath returns the path of a value such as "items[0].id".
+func formatPath(path []interface{}) string {
+	var formatted string
+	for _, p := range path {
+		switch p := p.(type) {
+		case int:
+			formatted += fmt.Sprintf("[%d]", p)
+		default:
+			if formatted != "" {
+				formatted += "."
+			}
+			formatted += fmt.Sprint(p)
+		}
+	}
+	return formatted
+}
+
+// diff returns the lines of the diff of the matcher and the value in the actual response if the value does not satisfy the matcher.
+func (matcher fieldMatcher) diff(actual protoreflect.Message) []string {
+	expected, _ := json.Marshal(matcher.conditions)
+	target, err := resolveMatcherTarget(actual, matcher.path)
+	if err != nil {
+		return diffLines(formatPath(matcher.path), string(expected), err.Error())
+	}
+	names := make([]string, 0, len(matcher.conditions))
+	for name := range matcher.conditions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := target.check(name, matcher.conditions[name]); err != nil {
+			return diffLines(target.path, string(expected), fmt.Sprintf("%s (%v)", target.format(), err))
+		}
+	}
+	return nil
+}
+
+// matcherTarget is the field or the map entry in the actual response which a matcher is applied to.
+type matcherTarget struct {
+	// message is the message which has the field, or the map field of the entry.
+	message protoreflect.Message
+	fd      protoreflect.FieldDescriptor
+	// key is the key of the map entry if isEntry is true.
+	key     protoreflect.MapKey
+	isEntry bool
+	// found reports whether the messages and the map entries on the path to the target exist.
+	found bool
+	path  string
+}
+
+// resolveMatcherTarget resolves the path of a matcher in the message.
+// The keys of the JSON objects are resolved as the names of the fields or the keys of the maps,
+// and the indexes of the JSON arrays as the indexes of the repeated fields.
+func resolveMatcherTarget(message protoreflect.Message, path []interface{}) (matcherTarget, error) {
+	target := matcherTarget{found: true}
+	for i := 0; i < len(path); {
+		name := fmt.Sprint(path[i])
+		i++
+		fields := message.Descriptor().Fields()
+		fd := fields.ByJSONName(name)
+		if fd == nil {
+			fd = fields.ByName(protoreflect.Name(name))
+		}
+		if fd == nil {
+			return target, fmt.Errorf("%s has no field %q", message.Descriptor().FullName(), name)
+		}
+		if target.path != "" {
+			name = "." + name
+		}
+		target = matcherTarget{message: message, fd: fd, found: target.found, path: target.path + name}
+		if i == len(path) {
+			return target, nil
+		}
+		var next protoreflect.Value
+		switch {
+		case fd.IsMap():
+			key, err := parseMapKey(fd.MapKey(), fmt.Sprint(path[i]))
+			if err != nil {
+				return target, err
+			}
+			target.key, target.isEntry = key, true
+			target.path += fmt.Sprintf("[%q]", fmt.Sprint(path[i]))
+			i++
+			if i == len(path) {
+				return target, nil
+			}
+			if fd.MapValue().Message() == nil {
+				return target, fmt.Errorf("the values of %s are not messages", target.path)
+			}
+			next = message.Get(fd).Map().Get(key)
+		case fd.IsList():
+			index, _ := path[i].(int)
+			target.path += fmt.Sprintf("[%d]", index)
+			i++
+			if fd.Message() == nil {
+				return target, fmt.Errorf("the elements of %s are not messages", target.path)
+			}
+			if list := message.Get(fd).List(); index < list.Len() {
+				next = list.Get(index)
+			}
+		case fd.Message() != nil:
+			if message.Has(fd) {
+				next = message.Get(fd)
+			}
+		default:
+			return target, fmt.Errorf("%s is not a message", target.path)
+		}
+		if !next.IsValid() {
+			// The rest of the path is resolved in an empty message, so that the target is reported as unset.
+			target.found = false
+			next = protoreflect.ValueOfMessage(message.Get(fd).Message().New())
+			if fd.IsMap() {
+				next = protoreflect.ValueOfMessage(message.Get(fd).Map().NewValue().Message())
+			} else if fd.IsList() {
+				next = protoreflect.ValueOfMessage(message.Get(fd).List().NewElement().Message())
+			}
+		}
+		message = next.Message()
+	}
+	return target, nil
+}
+
+// parseMapKey parses the key of a map written as the key of a JSON object.
+func parseMapKey(fd protoreflect.FieldDescriptor, key string) (protoreflect.MapKey, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(key).MapKey(), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(key)
+		return protoreflect.ValueOfBool(b).MapKey(), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(key, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)).MapKey(), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(key, 10, 64)
+		return protoreflect.ValueOfInt64(n).MapKey(), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(key, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)).MapKey(), err
+	default:
+		n, err := strconv.ParseUint(key, 10, 64)
+		return protoreflect.ValueOfUint64(n).MapKey(), err
+	}
+}
+
+// value returns the value of the target and reports whether it is set.
+func (target matcherTarget) value() (protoreflect.Value, bool) {
+	if !target.found {
+		return protoreflect.Value{}, false
+	}
+	if target.isEntry {
+		m := target.message.Get(target.fd).Map()
+		return m.Get(target.key), m.Has(target.key)
+	}
+	v := target.message.Get(target.fd)
+	switch {
+	case target.fd.IsList():
+		return v, v.List().Len() > 0
+	case target.fd.IsMap():
+		return v, v.Map().Len() > 0
+	case target.fd.HasPresence():
+		return v, target.message.Has(target.fd)
+	}
+	return v, true
+}
+
+// valueFd returns the descriptor of the value of the target, which is the descriptor of the values of the map for a map entry.
+func (target matcherTarget) valueFd() protoreflect.FieldDescriptor {
+	if target.isEntry {
+		return target.fd.MapValue()
+	}
+	return target.fd
+}
+
+// clear clears the target in the message.
+func (target matcherTarget) clear() {
+	if !target.found {
+		return
+	}
+	if target.isEntry {
+		target.message.Mutable(target.fd).Map().Clear(target.key)
+		return
+	}
+	target.message.Clear(target.fd)
+}
+
+// isCollection reports whether the target is a repeated field or a map field.
+func (target matcherTarget) isCollection() bool {
+	return !target.isEntry && (target.fd.IsList() || target.fd.IsMap())
+}
+
+// format returns the string of the value of the target in the same form as protojson.
+func (target matcherTarget) format() string {
+	v, ok := target.value()
+	if !ok {
+		return "(unset)"
+	}
+	fd := target.valueFd()
+	switch {
+	case target.isCollection() && fd.IsList():
+		elements := make([]string, v.List().Len())
+		for i := range elements {
+			elements[i] = formatValue(fd, v.List().Get(i))
+		}
+		return "[" + strings.Join(elements, ",") + "]"
+	case target.isCollection():
+		var entries []string
+		v.Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+			entries = append(entries, strconv.Quote(key.String())+":"+formatValue(fd.MapValue(), value))
+			return true
+		})
+		sort.Strings(entries)
+		return "{" + strings.Join(entries, ",") + "}"
+	}
+	return formatValue(fd, v)
+}
+
+// check returns an error if the target does not satisfy the condition of the matcher.
+func (target matcherTarget) check(name string, condition interface{}) error {
+	v, ok := target.value()
+	fd := target.valueFd()
+	isCollection := target.isCollection()
+	switch name {
+	case matcherAny:
+		return nil
+	case matcherNotEmpty:
+		expected, _ := condition.(bool)
+		empty := !ok || (!isCollection && fd.Message() == nil && v.Equal(fd.Default()))
+		if expected == empty {
+			return fmt.Errorf("%s is %v", name, expected)
+		}
+		return nil
+	}
+	if !ok && !isCollection {
+		return fmt.Errorf("%s requires the value", name)
+	}
+	switch name {
+	case matcherRegex:
+		pattern, _ := condition.(string)
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		if isCollection || fd.Kind() != protoreflect.StringKind {
+			return fmt.Errorf("%s applies only to strings", name)
+		}
+		if !re.MatchString(v.String()) {
+			return fmt.Errorf("does not match %s", pattern)
+		}
+	case matcherGt, matcherGte, matcherLt, matcherLte:
+		expected, isNumber := condition.(float64)
+		actual, ok := numberValue(fd, v)
+		if !isNumber || !ok || isCollection {
+			return fmt.Errorf("%s applies only to numbers", name)
+		}
+		if (name == matcherGt && actual <= expected) || (name == matcherGte && actual < expected) ||
+			(name == matcherLt && actual >= expected) || (name == matcherLte && actual > expected) {
+			return fmt.Errorf("is out of the range %s %v", name, expected)
+		}
+	case matcherLen:
+		expected, _ := condition.(float64)
+		var length int
+		switch {
+		case isCollection && fd.IsList():
+			length = v.List().Len()
+		case isCollection:
+			length = v.Map().Len()
+		case fd.Kind() == protoreflect.StringKind:
+			length = utf8.RuneCountInString(v.String())
+		case fd.Kind() == protoreflect.BytesKind:
+			length = len(v.Bytes())
+		default:
+			return fmt.Errorf("%s applies only to strings, bytes, repeated fields and maps", name)
+		}
+		if length != int(expected) {
+			return fmt.Errorf("the length is %d", length)
+		}
+	case matcherContains:
+		return target.checkContains(condition)
+	case matcherWithin:
+		return target.checkWithin(condition)
+	}
+	return nil
+}
+
+// checkContains returns an error if the target does not contain the condition of $contains.
+// A string must contain the substring, a repeated field must have an element which matches the value partially,
+// and a map must have the entries which match the entries of the value partially.
+func (target matcherTarget) checkContains(condition interface{}) error {
+	v, _ := target.value()
+	fd := target.valueFd()
+	if !target.isCollection() {
+		substr, ok := condition.(string)
+		if !ok || fd.Kind() != protoreflect.StringKind {
+			return fmt.Errorf("%s applies only to strings, repeated fields and maps", matcherContains)
+		}
+		if !strings.Contains(v.String(), substr) {
+			return fmt.Errorf("does not contain %q", substr)
+		}
+		return nil
+	}
+	// The value is decoded with protojson as the field of a new message, so that it is written in the same way as the field.
+	var values interface{} = condition
+	if fd.IsList() {
+		values = []interface{}{condition}
+	}
+	valueJSON, err := json.Marshal(map[string]interface{}{fd.JSONName(): values})
+	if err != nil {
+		return err
+	}
+	expected := target.message.New()
+	if err := protojson.Unmarshal(valueJSON, expected.Interface()); err != nil {
+		return fmt.Errorf("invalid %s: %v", matcherContains, err)
+	}
+	matches := func(elemFd protoreflect.FieldDescriptor, expectedValue interface{}, expected, actual protoreflect.Value) bool {
+		if elemFd.Message() == nil {
+			return expected.Equal(actual)
+		}
+		var lines []string
+		diffMessagesPartially("", expectedValue, expected.Message(), actual.Message(), &lines)
+		return len(lines) == 0
+	}
+	if fd.IsList() {
+		list := v.List()
+		for i := 0; i < list.Len(); i++ {
+			if matches(fd, condition, expected.Get(fd).List().Get(0), list.Get(i)) {
+				return nil
+			}
+		}
+		return fmt.Errorf("no element matches %s", valueJSON)
+	}
+	entries, _ := condition.(map[string]interface{})
+	var entryErr error
+	expected.Get(fd).Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+		if !v.Map().Has(key) || !matches(fd.MapValue(), entries[key.String()], value, v.Map().Get(key)) {
+			entryErr = fmt.Errorf("the entry %q does not match", key.String())
+			return false
+		}
+		return true
+	})
+	return entryErr
+}
+
+// checkWithin returns an error if the target is not a google.protobuf.Timestamp within the duration of $within from now.
+func (target matcherTarget) checkWithin(condition interface{}) error {
+	v, _ := target.value()
+	fd := target.valueFd()
+	if target.isCollection() || fd.Message() == nil || fd.Message().FullName() != "google.protobuf.Timestamp" {
+		return fmt.Errorf("%s applies only to google.protobuf.Timestamp", matcherWithin)
+	}
+	s, _ := condition.(string)
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", matcherWithin, err)
+	}
+	m := v.Message()
+	fields := m.Descriptor().Fields()
+	t := time.Unix(m.Get(fields.ByName("seconds")).Int(), m.Get(fields.ByName("nanos")).Int())
+	if diff := time.Since(t); diff > d || diff < -d {
+		return fmt.Errorf("%v from now", diff.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// numberValue returns the value of the field as float64 if the field is a number.
+func numberValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) (float64, bool) {
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return float64(v.Int()), true
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return float64(v.Uint()), true
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// expandVariables returns a copy of the test case in which the references to the variables such as ${order_id} are
+// replaced with the values of the variables, and validates the values again. capture is not expanded.
+func expandVariables(testCase map[string]interface{}, variables map[string]interface{}, index int) (map[string]interface{}, error) {
+	expanded := make(map[string]interface{}, len(testCase))
+	for key, value := range testCase {
+		if key == captureJSONKey {
+			expanded[key] = value
+			continue
+		}
+		v, err := expandValue(value, variables)
+		if err != nil {
+			return nil, fmt.Errorf("case #%d: %s: %v", index, key, err)
+		}
+		expanded[key] = v
+	}
+	if err := checkExpandedTestCase(expanded); err != nil {
+		return nil, fmt.Errorf("case #%d: %v", index, err)
+	}
+	return expanded, nil
+}
+
+// checkExpandedTestCase validates the values of the test case in which the variables are expanded,
+// because a reference to a variable is accepted as the value of any key when the scenario is loaded.
+func checkExpandedTestCase(testCase map[string]interface{}) error {
+	v := &scenarioValidator{}
+	for _, key := range sortedKeys(testCase) {
+		if field, ok := testCaseFields[key]; ok && key != captureJSONKey {
+			field.validate(v, []interface{}{key}, testCase[key])
+		}
+	}
+	if len(v.errors) == 0 {
+		return nil
+	}
+	messages := make([]string, len(v.errors))
+	for i, e := range v.errors {
+		messages[i] = e.message
+	}
+	return fmt.Errorf("the expanded values are invalid: %s", strings.Join(messages, ", "))
+}
+
+// expandValue returns a copy of the value of the scenario in which the variables are expanded.
+// A string which consists of only a reference is replaced with the value of the variable as it is,
+// so that a number or an object can be referred to. Otherwise the references are replaced with the strings of the values.
+func expandValue(value interface{}, variables map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			e, err := expandValue(element, variables)
+			if err != nil {
+				return nil, err
+			}
+			expanded[key] = e
+		}
+		return expanded, nil
+	case []interface{}:
+		expanded := make([]interface{}, len(v))
+		for i, element := range v {
+			e, err := expandValue(element, variables)
+			if err != nil {
+				return nil, err
+			}
+			expanded[i] = e
+		}
+		return expanded, nil
+	case string:
+		if m := variablePattern.FindStringSubmatch(v); m != nil && m[0] == v && m[1] == "" {
+			variable, ok := variables[m[2]]
+			if !ok {
+				return nil, fmt.Errorf("undefined variable %q", m[2])
+			}
+			return variable, nil
+		}
+		var err error
+		expanded := variablePattern.ReplaceAllStringFunc(v, func(reference string) string {
+			m := variablePattern.FindStringSubmatch(reference)
+			if m[1] != "" {
+				return reference[1:]
+			}
+			variable, ok := variables[m[2]]
+			if !ok {
+				err = fmt.Errorf("undefined variable %q", m[2])
+				return reference
+			}
+			if s, ok := variable.(string); ok {
+				return s
+			}
+			variableJSON, _ := json.Marshal(variable)
+			return string(variableJSON)
+		})
+		return expanded, err
+	}
+	return value, nil
+}
+
+// captureVariables stores the values captured according to capture of the test case into variables.
+// responses are the responses received in the test case, where nil responses are ignored,
+// and err is the error returned by the RPC.
+func captureVariables(testCase map[string]interface{}, variables map[string]interface{}, index int, err error, responses ...proto.Message) error {
+	captures, _ := testCase[captureJSONKey].(map[string]interface{})
+	var received []proto.Message
+	for _, res := range responses {
+		if res != nil && res.ProtoReflect().IsValid() {
+			received = append(received, res)
+		}
+	}
+	names := make([]string, 0, len(captures))
+	for name := range captures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path, _ := captures[name].(string)
+		value, captureErr := captureValue(path, received, err)
+		if captureErr != nil {
+			return fmt.Errorf("case #%d: %s.%s: failed to capture %q: %v", index, captureJSONKey, name, path, captureErr)
+		}
+		variables[name] = value
+	}
+	return nil
+}
+
+// captureValue returns the value at the path as a JSON value in the same form as protojson.
+// The path starts with "response", which is the last response, "responses[i]", which is the i-th response,
+// or "error", which is the status of the error such as "error.code" and "error.details[0].reason".
+func captureValue(path string, responses []proto.Message, err error) (interface{}, error) {
+	steps, parseErr := parseCapturePath(path)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	var root proto.Message
+	switch {
+	case steps[0] == captureResponse:
+		if len(responses) == 0 {
+			return nil, errors.New("no response was received")
+		}
+		root, steps = responses[len(responses)-1], steps[1:]
+	case steps[0] == captureResponses && len(steps) > 1:
+		j, ok := steps[1].(int)
+		if !ok || j >= len(responses) {
+			return nil, fmt.Errorf("the response #%v was not received", steps[1])
+		}
+		root, steps = responses[j], steps[2:]
+	case steps[0] == captureError:
+		if err == nil {
+			return nil, errors.New("no error was returned")
+		}
+		root, steps = status.Convert(err).Proto(), steps[1:]
+	default:
+		return nil, fmt.Errorf("the path must start with %q, %q or %q", captureResponse, captureResponses+"[i]", captureError)
+	}
+	return lookupValue(root.ProtoReflect(), steps)
+}
+
+// parseCapturePath parses the path of a capture into the field names, the indexes of the repeated fields
+// and the keys of the maps. The keys of the maps can be written as field names such as "labels.team" as well.
+func parseCapturePath(path string) ([]interface{}, error) {
+	var steps []interface{}
+	for rest := path; rest != ""; {
+		m := capturePathPattern.FindStringSubmatch(rest)
+		if m == nil || (m[2] != "" && (m[1] == "") != (len(steps) == 0)) {
+			return nil, fmt.Errorf("invalid path at %q", rest)
+		}
+		switch {
+		case m[2] != "":
+			steps = append(steps, m[2])
+		case m[3] != "":
+			n, err := strconv.Atoi(m[3])
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, n)
+		default:
+			key, err := strconv.Unquote(m[4])
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, key)
+		}
+		rest = rest[len(m[0]):]
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("the path is empty")
+	}
+	return steps, nil
+}
+
+// lookupValue returns the value at the path in the message as a JSON value.
+// google.protobuf.Any is unpacked, so that the fields of the packed message can be referred to.
+func lookupValue(message protoreflect.Message, path []interface{}) (interface{}, error) {
+	var fd protoreflect.FieldDescriptor
+	v := protoreflect.ValueOfMessage(message)
+	isCollection := false
+	for _, step := range path {
+		switch {
+		case isCollection && fd.IsList():
+			index, ok := step.(int)
+			if !ok {
+				return nil, fmt.Errorf("%s is a repeated field, which needs an index", fd.Name())
+			}
+			if index >= v.List().Len() {
+				return nil, fmt.Errorf("%s has no element #%d", fd.Name(), index)
+			}
+			v, isCollection = v.List().Get(index), false
+		case isCollection:
+			key, err := parseMapKey(fd.MapKey(), fmt.Sprint(step))
+			if err != nil {
+				return nil, err
+			}
+			if !v.Map().Has(key) {
+				return nil, fmt.Errorf("%s has no entry %q", fd.Name(), fmt.Sprint(step))
+			}
+			v, fd, isCollection = v.Map().Get(key), fd.MapValue(), false
+		default:
+			name, ok := step.(string)
+			if !ok || (fd != nil && fd.Message() == nil) {
+				return nil, fmt.Errorf("%v is not a field of a message", step)
+			}
+			m, err := unpackAny(v.Message())
+			if err != nil {
+				return nil, err
+			}
+			fields := m.Descriptor().Fields()
+			field := fields.ByJSONName(name)
+			if field == nil {
+				field = fields.ByName(protoreflect.Name(name))
+			}
+			if field == nil {
+				return nil, fmt.Errorf("%s has no field %q", m.Descriptor().FullName(), name)
+			}
+			if field.HasPresence() && !m.Has(field) {
+				return nil, fmt.Errorf("%s is not set", field.Name())
+			}
+			v, fd, isCollection = m.Get(field), field, field.IsList() || field.IsMap()
+		}
+	}
+	switch {
+	case fd == nil:
+		return messageJSONValue(v.Message())
+	case isCollection && fd.IsList():
+		values := make([]interface{}, v.List().Len())
+		for i := range values {
+			value, err := jsonValue(fd, v.List().Get(i))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case isCollection:
+		entries := map[string]interface{}{}
+		var err error
+		v.Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+			entries[key.String()], err = jsonValue(fd.MapValue(), value)
+			return err == nil
+		})
+		return entries, err
+	}
+	return jsonValue(fd, v)
+}
+
+// unpackAny returns the message packed in m if m is a google.protobuf.Any, or m itself otherwise.
+// The type of the packed message must be registered in protoregistry.GlobalTypes.
+func unpackAny(m protoreflect.Message) (protoreflect.Message, error) {
+	if m.Descriptor().FullName() != "google.protobuf.Any" {
+		return m, nil
+	}
+	fields := m.Descriptor().Fields()
+	messageType, err := protoregistry.GlobalTypes.FindMessageByURL(m.Get(fields.ByName("type_url")).String())
+	if err != nil {
+		return nil, err
+	}
+	packed := messageType.New()
+	if err := proto.Unmarshal(m.Get(fields.ByName("value")).Bytes(), packed.Interface()); err != nil {
+		return nil, err
+	}
+	return packed, nil
+}
+
+// jsonValue returns the value of the field as a JSON value in the same form as protojson.
+func jsonValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) (interface{}, error) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageJSONValue(v.Message())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name()), nil
+		}
+		return float64(v.Enum()), nil
+	case protoreflect.StringKind:
+		return v.String(), nil
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+	case protoreflect.BoolKind:
+		return v.Bool(), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// 64-bit integers are strings in protojson, so that they are not rounded.
+		return strconv.FormatInt(v.Int(), 10), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	}
+	n, _ := numberValue(fd, v)
+	return n, nil
+}
+
+// messageJSONValue returns the message as a JSON value in the same form as protojson.
+func messageJSONValue(m protoreflect.Message) (interface{}, error) {
+	messageJSON, err := protojson.Marshal(m.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	err = json.Unmarshal(messageJSON, &value)
+	return value, err
+}
+
+// checkError returns an error if err does not match expected_error_code, expected_error_message and
+// expected_error_details of the test case. The test case without them expects any error.
+// The details are compared according to match.
+func checkError(testCase map[string]interface{}, index int, match string, err error) error {
+	if err == nil {
+		return errors.New("no error was returned")
+	}
+	st := status.Convert(err)
+	if value, ok := testCase[expectedErrorCodeJSONKey]; ok {
+		code, parseErr := parseCode(value)
+		if parseErr != nil {
+			return fmt.Errorf("case #%d: %s: %v", index, expectedErrorCodeJSONKey, parseErr)
+		}
+		if code != st.Code() {
+			return fmt.Errorf("the error code was not equal to the expected code. Expected: %v, Actual: %v (%s)", code, st.Code(), st.Message())
+		}
+	}
+	if value, ok := testCase[expectedErrorMessageJSONKey]; ok {
+		expectedJSON, _ := json.Marshal(value)
+		if conditions, ok := matcherConditions(value); ok {
+			if err := validateMatcher(conditions); err != nil {
+				return fmt.Errorf("case #%d: %s: %v", index, expectedErrorMessageJSONKey, err)
+			}
+			if err := checkStringValues(conditions, []string{st.Message()}); err != nil {
+				return fmt.Errorf("the error message did not match the expected message. Expected: %s, Actual: %q (%v)", expectedJSON, st.Message(), err)
+			}
+		} else if expected, ok := value.(string); !ok {
+			return fmt.Errorf("case #%d: %s must be a string or a matcher", index, expectedErrorMessageJSONKey)
+		} else if expected != st.Message() {
+			return fmt.Errorf("the error message was not equal to the expected message. Expected: %s, Actual: %q", expectedJSON, st.Message())
+		}
+	}
+	if value, ok := testCase[expectedErrorDetailsJSONKey]; ok {
+		return checkErrorDetails(value, index, match, st)
+	}
+	return nil
+}
+
+// describeError returns the description of the error with the status code, the message and the details in protojson
+// if the error is a status, or the string of the error otherwise.
+func describeError(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err.Error()
+	}
+	description := fmt.Sprintf("code = %v, message = %q", st.Code(), st.Message())
+	details := statusDetails(st)
+	if details.Len() == 0 {
+		return description
+	}
+	formatted := make([]string, details.Len())
+	for i := range formatted {
+		formatted[i] = formatMessage(details.Get(i).Message())
+	}
+	return description + ", details = [" + strings.Join(formatted, ", ") + "]"
+}
+
+// statusDetails returns the details of the status, which are google.protobuf.Any.
+func statusDetails(st *status.Status) protoreflect.List {
+	statusMessage := st.Proto().ProtoReflect()
+	return statusMessage.Get(statusMessage.Descriptor().Fields().ByName("details")).List()
+}
+
+// parseCode parses the status code written as a number, or a name such as "InvalidArgument" or "INVALID_ARGUMENT".
+func parseCode(value interface{}) (codes.Code, error) {
+	switch v := value.(type) {
+	case float64:
+		if v >= 0 && v == float64(uint32(v)) {
+			return codes.Code(uint32(v)), nil
+		}
+	case string:
+		for code := codes.OK; code <= codes.Unauthenticated; code++ {
+			if code.String() == v {
+				return code, nil
+			}
+		}
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(strconv.Quote(v))); err == nil {
+			return code, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown code %v", value)
+}
+
+// checkErrorDetails returns an error if an expected detail is not found in the details of the status.
+// The expected details are written in protojson with "@type", and each of them must match one of the details
+// of the same type, which is compared according to match. The details not expected are ignored.
+func checkErrorDetails(value interface{}, index int, match string, st *status.Status) error {
+	values, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("case #%d: %s must be an array", index, expectedErrorDetailsJSONKey)
+	}
+	details := statusDetails(st)
+	actualDetails := make([]protoreflect.Message, details.Len())
+	for i := range actualDetails {
+		detail, err := unpackAny(details.Get(i).Message())
+		if err != nil {
+			// The detail of an unknown type is compared as google.protobuf.Any, which no expected detail matches.
+			detail = details.Get(i).Message()
+		}
+		actualDetails[i] = detail
+	}
+	matched := make([]bool, len(actualDetails))
+	for j, v := range values {
+		path := fmt.Sprintf("%s[%d]", expectedErrorDetailsJSONKey, j)
+		object, _ := v.(map[string]interface{})
+		typeURL, _ := object["@type"].(string)
+		messageType, err := protoregistry.GlobalTypes.FindMessageByURL(typeURL)
+		if err != nil {
+			return fmt.Errorf("case #%d: %s: unknown @type %q: %v", index, path, typeURL, err)
+		}
+		expectedValue := make(map[string]interface{}, len(object))
+		for key, field := range object {
+			if key != "@type" {
+				expectedValue[key] = field
+			}
+		}
+		expected := messageType.New().Interface()
+		if err := decodeExpectedMessage(expectedValue, expected, index, path); err != nil {
+			return err
+		}
+		var diff string
+		found := false
+		for k, actual := range actualDetails {
+			if matched[k] || actual.Descriptor().FullName() != messageType.Descriptor().FullName() {
+				continue
+			}
+			d := diffResponses(match, expectedValue, expected, actual.Interface())
+			if d == "" {
+				matched[k], found = true, true
+				break
+			}
+			if diff == "" {
+				diff = d
+			}
+		}
+		switch {
+		case found:
+		case diff == "":
+			return fmt.Errorf("the expected detail #%d (%s) was not found in the actual details", j, messageType.Descriptor().FullName())
+		default:
+			return fmt.Errorf("the expected detail #%d was not equal to the actual detail (-expected +actual):\n%s", j, diff)
+		}
+	}
+	return nil
+}
+
+// callLimits returns timeout and max_latency of the test case, which are written as durations accepted by parseDuration.
+// Zero means that the call is not limited.
+func callLimits(testCase map[string]interface{}, index int) (timeout, maxLatency time.Duration, err error) {
+	if timeout, err = durationField(testCase, timeoutJSONKey); err != nil {
+		return 0, 0, fmt.Errorf("case #%d: %v", index, err)
+	}
+	if maxLatency, err = durationField(testCase, maxLatencyJSONKey); err != nil {
+		return 0, 0, fmt.Errorf("case #%d: %v", index, err)
+	}
+	return timeout, maxLatency, nil
+}
+
+// parseDuration parses the value of a duration, which is a number of seconds such as 1.5,
+// or a string of time.ParseDuration such as "250ms".
+func parseDuration(value interface{}) (time.Duration, error) {
+	var d time.Duration
+	switch v := value.(type) {
+	case float64:
+		d = time.Duration(v * float64(time.Second))
+	case string:
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			d = -1
+		}
+	default:
+		d = -1
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("must be a non-negative number of seconds or a duration such as \"250ms\", but got %v", value)
+	}
+	return d, nil
+}
+
+// durationField returns the duration of the key of the test case, or 0 if the test case does not have the key.
+func durationField(testCase map[string]interface{}, key string) (time.Duration, error) {
+	value, ok := testCase[key]
+	if !ok {
+		return 0, nil
+	}
+	d, err := parseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s %v", key, err)
+	}
+	return d, nil
+}
+
+// callContext returns the context of a call, which has the deadline after timeout unless timeout is zero.
+func callContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// checkLatency returns an error if latency exceeds maxLatency unless maxLatency is zero.
+func checkLatency(latency, maxLatency time.Duration) error {
+	if maxLatency != 0 && latency > maxLatency {
+		return fmt.Errorf("the call took %v, which exceeded %s %v", latency.Round(time.Millisecond), maxLatencyJSONKey, maxLatency)
+	}
+	return nil
+}
+
+// checkCall checks the latency, the header and the trailer of the call, and returns the failures.
+func checkCall(testCase map[string]interface{}, latency, maxLatency time.Duration, header, trailer metadata.MD) []error {
+	var failures []error
+	if err := checkLatency(latency, maxLatency); err != nil {
+		failures = append(failures, err)
+	}
+	if err := checkMetadata(testCase, header, trailer); err != nil {
+		failures = append(failures, err)
+	}
+	return failures
+}
+
+// schedule decides when the iterations of a test case run. Without eventually, the iterations run loop times,
+// sleeping sleep before each of them and waiting interval between them. With eventually, they run until one of them
+// passes or the duration of eventually passes, waiting interval after the first failure and twice as long after each of
+// the following ones, up to maxEventuallyInterval with jitter.
+type schedule struct {
+	loop        int
+	successRule string
+	sleep       time.Duration
+	interval    time.Duration
+	eventually  bool
+	within      time.Duration
+	deadline    time.Time
+}
+
+// newSchedule returns the schedule of the test case at index.
+func newSchedule(testCase map[string]interface{}, index int) (*schedule, error) {
+	s := &schedule{loop: 1, successRule: successRuleAll}
+	if v, ok := testCase[loopJSONKey].(float64); ok {
+		s.loop = int(v)
+	}
+	if v, ok := testCase[successRuleJSONKey].(string); ok {
+		s.successRule = v
+	}
+	var err error
+	if s.sleep, err = durationField(testCase, sleepJSONKey); err != nil {
+		return nil, fmt.Errorf("case #%d: %v", index, err)
+	}
+	if s.interval, err = durationField(testCase, intervalJSONKey); err != nil {
+		return nil, fmt.Errorf("case #%d: %v", index, err)
+	}
+	if s.within, err = durationField(testCase, eventuallyJSONKey); err != nil {
+		return nil, fmt.Errorf("case #%d: %v", index, err)
+	}
+	if _, ok := testCase[eventuallyJSONKey]; ok {
+		s.eventually, s.successRule = true, successRuleOnce
+		if _, ok := testCase[intervalJSONKey]; !ok {
+			s.interval = defaultEventuallyInterval
+		}
+	}
+	return s, nil
+}
+
+// next waits for the iteration i, and reports whether it runs.
+func (s *schedule) next(i int) bool {
+	switch {
+	case i == 1:
+		// The deadline of eventually starts after sleep, which delays only the first attempt.
+		time.Sleep(s.sleep)
+		s.deadline = time.Now().Add(s.within)
+	case s.eventually:
+		time.Sleep(s.backoff(i))
+	case i > s.loop:
+		return false
+	default:
+		time.Sleep(s.interval)
+		time.Sleep(s.sleep)
+	}
+	return true
+}
+
+// retries reports whether the iteration i is tried again if it fails.
+func (s *schedule) retries(i int) bool {
+	if s.eventually {
+		return time.Now().Before(s.deadline)
+	}
+	return i < s.loop
+}
+
+// backoff returns the wait of eventually before the iteration i, which does not pass the deadline.
+// A half of the wait is random, so that the iterations of the test cases run in parallel are spread.
+func (s *schedule) backoff(i int) time.Duration {
+	limit := maxEventuallyInterval
+	if s.interval > limit {
+		limit = s.interval
+	}
+	wait := s.interval
+	for j := 2; j < i && wait < limit; j++ {
+		wait *= 2
+	}
+	if wait > limit {
+		wait = limit
+	}
+	wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+	if remaining := time.Until(s.deadline); wait > remaining {
+		wait = remaining
+	}
+	return wait
+}
+
+// caseReporter reports the failures of the iterations of a test case.
+// By default, the first failure stops the test case with t.Fatal. In the continue-on-failure mode, every failure is
+// reported with t.Errorf and the test case goes on, and how many iterations passed is logged at the end.
+type caseReporter struct {
+	t                 *testing.T
+	continueOnFailure bool
+	// loop is the number of the iterations, or 0 if they run until eventually passes.
+	loop       int
+	iterations int
+	passed     int
+}
+
+// newCaseReporter returns a caseReporter of the test case, which is in the continue-on-failure mode
+// if continue_on_failure of the test case is true, or if it is omitted and defaultContinueOnFailure is true.
+func newCaseReporter(t *testing.T, testCase map[string]interface{}, defaultContinueOnFailure bool) *caseReporter {
+	continueOnFailure := defaultContinueOnFailure
+	if v, ok := testCase[continueOnFailureJSONKey].(bool); ok {
+		continueOnFailure = v
+	}
+	loop := 1
+	if v, ok := testCase[loopJSONKey].(float64); ok {
+		loop = int(v)
+	}
+	if _, ok := testCase[eventuallyJSONKey]; ok {
+		loop = 0
+	}
+	return &caseReporter{t: t, continueOnFailure: continueOnFailure, loop: loop}
+}
+
+// check reports the failures of the iteration, which has passed if there are none.
+func (r *caseReporter) check(iteration int, failures []error) {
+	r.t.Helper()
+	r.iterations++
+	if len(failures) == 0 {
+		r.passed++
+		return
+	}
+	for _, failure := range failures {
+		message := r.format(iteration, failure)
+		if !r.continueOnFailure {
+			r.t.Fatal(message)
+		}
+		r.t.Error(message)
+	}
+}
+
+// retry records the failed iteration, which is tried again because of the success rule "once".
+// The failures are logged in the continue-on-failure mode.
+func (r *caseReporter) retry(iteration int, failures []error) {
+	r.t.Helper()
+	r.iterations++
+	if r.continueOnFailure {
+		for _, failure := range failures {
+			r.t.Log(r.format(iteration, failure))
+		}
+	}
+}
+
+// reportIteration reports the failures of the iteration i according to the success rule of s,
+// and reports whether the test case goes on to the next iteration.
+// With the success rule "once", a failed iteration is retried while s allows it, and the first passed one ends the test case.
+func reportIteration(r *caseReporter, s *schedule, i int, failures []error) bool {
+	r.t.Helper()
+	if s.successRule == successRuleOnce {
+		if len(failures) > 0 && s.retries(i) {
+			r.retry(i, failures)
+			return true
+		}
+		r.check(i, failures)
+		return false
+	}
+	r.check(i, failures)
+	return true
+}
+
+// summarize logs how many iterations passed in the continue-on-failure mode.
+func (r *caseReporter) summarize() {
+	r.t.Helper()
+	if r.continueOnFailure {
+		r.t.Logf("%d of %d iterations passed", r.passed, r.iterations)
+	}
+}
+
+// format returns the message of the failure, which has the iteration if the test case has more than one.
+func (r *caseReporter) format(iteration int, failure error) string {
+	switch {
+	case r.loop == 0:
+		return fmt.Sprintf("iteration %d: %v", iteration, failure)
+	case r.loop > 1:
+		return fmt.Sprintf("iteration %d of %d: %v", iteration, r.loop, failure)
+	}
+	return failure.Error()
+}
+
+// outgoingContext returns the context which has the metadata of the test case to send.
+func outgoingContext(ctx context.Context, testCase map[string]interface{}, index int) (context.Context, error) {
+	value, ok := testCase[metadataJSONKey]
+	if !ok {
+		return ctx, nil
+	}
+	md, err := decodeMetadata(value)
+	if err != nil {
+		return nil, fmt.Errorf("case #%d: %s: %v", index, metadataJSONKey, err)
+	}
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
+
+// decodeMetadata decodes the metadata written in the scenario, whose values are strings or arrays of strings.
+// The values of the binary keys, which end with "-bin", are written in base64.
+func decodeMetadata(value interface{}) (metadata.MD, error) {
+	entries, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("the metadata must be an object")
+	}
+	md := metadata.MD{}
+	for key, v := range entries {
+		values, ok := v.([]interface{})
+		if !ok {
+			values = []interface{}{v}
+		}
+		for _, element := range values {
+			s, ok := element.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: the values must be strings", key)
+			}
+			if isBinaryMetadataKey(key) {
+				b, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", key, err)
+				}
+				s = string(b)
+			}
+			md.Append(key, s)
+		}
+	}
+	return md, nil
+}
+
+// isBinaryMetadataKey reports whether the values of the metadata key are binary.
+func isBinaryMetadataKey(key string) bool {
+	return strings.HasSuffix(strings.ToLower(key), "-bin")
+}
+
+// checkMetadata returns an error if the header or the trailer does not match expected_header or expected_trailer of the test case.
+func checkMetadata(testCase map[string]interface{}, header, trailer metadata.MD) error {
+	for _, expectation := range []struct {
+		key    string
+		name   string
+		actual metadata.MD
+	}{
+		{expectedHeaderJSONKey, "header", header},
+		{expectedTrailerJSONKey, "trailer", trailer},
+	} {
+		value, ok := testCase[expectation.key]
+		if !ok {
+			continue
+		}
+		lines, err := diffMetadata(value, expectation.actual)
+		if err != nil {
+			return fmt.Errorf("%s: %v", expectation.key, err)
+		}
+		if len(lines) > 0 {
+			return fmt.Errorf("the actual %s was not equal to the expected %s (-expected +actual):\n%s", expectation.name, expectation.name, strings.Join(lines, "\n"))
+		}
+	}
+	return nil
+}
+
+// diffMetadata returns the lines of the diff of the keys written in the expected metadata.
+// The expected value of a key is a string, an array of strings, or a matcher applied to each value of the key.
+// The keys not written are ignored, and the values of the binary keys are compared in base64.
+func diffMetadata(expectedValue interface{}, actual metadata.MD) ([]string, error) {
+	entries, ok := expectedValue.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("the metadata must be an object")
+	}
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var lines []string
+	for _, key := range keys {
+		values := append([]string{}, actual.Get(key)...)
+		if isBinaryMetadataKey(key) {
+			for i, v := range values {
+				values[i] = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+		}
+		formatted := "(unset)"
+		if len(values) == 1 {
+			formatted = strconv.Quote(values[0])
+		} else if len(values) > 1 {
+			valuesJSON, _ := json.Marshal(values)
+			formatted = string(valuesJSON)
+		}
+		expectedJSON, _ := json.Marshal(entries[key])
+		if conditions, ok := matcherConditions(entries[key]); ok {
+			if err := validateMatcher(conditions); err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			if err := checkStringValues(conditions, values); err != nil {
+				lines = append(lines, diffLines(key, string(expectedJSON), fmt.Sprintf("%s (%v)", formatted, err))...)
+			}
+			continue
+		}
+		expected, ok := entries[key].([]interface{})
+		if !ok {
+			expected = []interface{}{entries[key]}
+		}
+		equal := len(expected) == len(values)
+		for i, e := range expected {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: the values must be strings or a matcher", key)
+			}
+			equal = equal && s == values[i]
+		}
+		if !equal {
+			lines = append(lines, diffLines(key, string(expectedJSON), formatted)...)
+		}
+	}
+	return lines, nil
+}
+
+// checkStringValues returns an error if a value does not satisfy the matcher.
+// No values are checked as an unset value.
+func checkStringValues(conditions map[string]interface{}, values []string) error {
+	newTarget := func(value string, found bool) matcherTarget {
+		message := wrapperspb.String(value).ProtoReflect()
+		return matcherTarget{message: message, fd: message.Descriptor().Fields().ByName("value"), found: found}
+	}
+	var targets []matcherTarget
+	for _, v := range values {
+		targets = append(targets, newTarget(v, true))
+	}
+	if len(targets) == 0 {
+		targets = append(targets, newTarget("", false))
+	}
+	names := make([]string, 0, len(conditions))
+	for name := range conditions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, target := range targets {
+		for _, name := range names {
+			if err := target.check(name, conditions[name]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
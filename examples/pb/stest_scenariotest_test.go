@@ -0,0 +1,189 @@
+package pb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeScenario writes the scenario to a file in a temporary directory, and returns the path of the file.
+func writeScenario(t *testing.T, scenario string) string {
+	jsonPath := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(jsonPath, []byte(scenario), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return jsonPath
+}
+
+// errorString returns the message of the error, in which the non-breaking spaces inserted randomly
+// by the protobuf module into its errors are replaced with spaces.
+func errorString(err error) string {
+	return strings.ReplaceAll(err.Error(), "\u00a0", " ")
+}
+
+func TestLoadScenarioErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		scenario string
+		errors   string
+	}{
+		{
+			name:     "syntax",
+			scenario: "[\n    {\"action\": \"Hello\",}\n]\n",
+			errors:   ":2:23: invalid character ',' looking for beginning of value",
+		},
+		{
+			name:     "unknown field",
+			scenario: "[\n    {\n        \"action\": \"Hello\",\n        \"request\": {\"message\": \"Hi\"}\n    }\n]\n",
+			errors:   "the scenario is invalid:\n{file}:4:20: [0].request: failed to decode the message: proto: (line 1:2): unknown field \"message\"",
+		},
+		{
+			name:     "expected responses",
+			scenario: "[\n    {\n        \"action\": \"Countdown\",\n        \"expected_responses\": [{\"count\": 1}, {\"count\": \"two\"}]\n    }\n]\n",
+			errors:   "the scenario is invalid:\n{file}:4:46: [0].expected_responses[1]: failed to decode the message: proto: (line 1:10): invalid value for int32 field count: \"two\"",
+		},
+		{
+			name:     "requests",
+			scenario: "[\n    {\n        \"action\": \"Sum\",\n        \"requests\": [{\"request\": {\"value\": true}}]\n    }\n]\n",
+			errors:   "the scenario is invalid:\n{file}:4:34: [0].requests[0].request: failed to decode the message: proto: (line 1:10): invalid value for int32 field value: true",
+		},
+		{
+			name:     "steps",
+			scenario: "[\n    {\n        \"action\": \"Echo\",\n        \"steps\": [\n            {\"send\": {\"msg\": \"a\"}},\n            {\"expect\": {\"message\": \"a\"}}\n        ]\n    }\n]\n",
+			errors:   "the scenario is invalid:\n{file}:6:24: [0].steps[1].expect: failed to decode the message: proto: (line 1:2): unknown field \"message\"",
+		},
+		{
+			name:     "matcher",
+			scenario: "[\n    {\n        \"action\": \"Hello\",\n        \"expected_response\": {\"res_msg\": {\"$like\": \"Hi\"}}\n    }\n]\n",
+			errors:   "the scenario is invalid:\n{file}:4:52: [0].expected_response.res_msg.$like: unknown matcher \"$like\"",
+		},
+		{
+			name:     "matcher conditions",
+			scenario: "[\n    {\n        \"action\": \"Countdown\",\n        \"expected_responses\": [\n            {\"count\": {\"$gt\": \"x\", \"$lt\": 3}},\n            {\"count\": {\"$len\": \"3\"}}\n        ]\n    },\n    {\n        \"action\": \"Bye\",\n        \"error_expectation\": true,\n        \"expected_error_message\": {\"$regex\": \"(\"},\n        \"expected_header\": {\"x-id\": {\"$not_empty\": \"yes\"}}\n    }\n]\n",
+			errors: "the scenario is invalid:\n" +
+				"{file}:5:31: [0].expected_responses[0].count.$gt: $gt must be a number\n" +
+				"{file}:6:32: [0].expected_responses[1].count.$len: $len must be a non-negative integer\n" +
+				"{file}:12:46: [1].expected_error_message.$regex: invalid $regex: error parsing regexp: missing closing ): `(`\n" +
+				"{file}:13:52: [1].expected_header.x-id.$not_empty: $not_empty must be a boolean",
+		},
+		{
+			name:     "default",
+			scenario: "{\n    \"request\": {\"req_msg\": 1},\n    \"cases\": [\n        {\"action\": \"Hello\"},\n        {\"action\": \"Hello\"}\n    ]\n}\n",
+			errors:   "the scenario is invalid:\n{file}:2:16: request: failed to decode the message: proto: (line 1:12): invalid value for string field reqMsg: 1",
+		},
+		{
+			name:     "eventually with default loop",
+			scenario: "{\n    \"loop\": 3,\n    \"cases\": [\n        {\"action\": \"Hello\", \"eventually\": \"1s\"},\n        {\"action\": \"Hello\", \"eventually\": \"2s\"}\n    ]\n}\n",
+			errors:   "the scenario is invalid:\n{file}:2:13: loop: cannot be used with eventually",
+		},
+		{
+			name:     "default eventually with success rule",
+			scenario: "{\n    \"eventually\": \"1s\",\n    \"cases\": [\n        {\"action\": \"Hello\"},\n        {\"action\": \"Hello\", \"success_rule\": \"once\"}\n    ]\n}\n",
+			errors:   "the scenario is invalid:\n{file}:5:45: cases[1].success_rule: cannot be used with eventually",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			jsonPath := writeScenario(t, c.scenario)
+			_, err := loadScenario(jsonPath, (&SampleTestRunner{}).methods())
+			if err == nil {
+				t.Fatal("the scenario was loaded")
+			}
+			expected := jsonPath + c.errors
+			if c.errors[0] != ':' {
+				expected = strings.ReplaceAll(c.errors, "{file}", jsonPath)
+			}
+			if errorString(err) != expected {
+				t.Errorf("unexpected error:\n%s\nexpected:\n%s", err, expected)
+			}
+		})
+	}
+}
+
+func TestLoadScenarioDecodesMessagesWithVariablesLater(t *testing.T) {
+	jsonPath := writeScenario(t, "[\n    {\n        \"action\": \"Hello\",\n        \"request\": {\"req_msg\": \"${greeting}\", \"unknown\": 1},\n        \"expected_response\": {\"res_msg\": {\"$regex\": \"^Hello\"}}\n    }\n]\n")
+	if _, err := loadScenario(jsonPath, (&SampleTestRunner{}).methods()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpandVariablesChecksTypes(t *testing.T) {
+	variables := map[string]interface{}{"message": "Hello!", "count": float64(2)}
+	cases := []struct {
+		testCase map[string]interface{}
+		err      string
+	}{
+		{
+			testCase: map[string]interface{}{actionJSONKey: "Hello", errorExpectationJSONKey: "${message}"},
+			err:      "case #1: the expanded values are invalid: error_expectation: must be a boolean",
+		},
+		{
+			testCase: map[string]interface{}{actionJSONKey: "Sum", requestsJSONKey: "${message}", loopJSONKey: "${message}"},
+			err:      "case #1: the expanded values are invalid: loop: must be a positive integer, requests: must be an array of objects",
+		},
+		{
+			testCase: map[string]interface{}{actionJSONKey: "Echo", stepsJSONKey: []interface{}{"${message}"}},
+			err:      "case #1: the expanded values are invalid: steps[0]: must be an object which has exactly one of [\"send\" \"expect\" \"expect_any_order\" \"close_send\" \"expect_eof\"]",
+		},
+	}
+	for _, c := range cases {
+		_, err := expandVariables(c.testCase, variables, 1)
+		if err == nil || err.Error() != c.err {
+			t.Errorf("unexpected error: %v, expected: %s", err, c.err)
+		}
+	}
+	expanded, err := expandVariables(map[string]interface{}{actionJSONKey: "Hello", loopJSONKey: "${count}"}, variables, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expanded[loopJSONKey] != float64(2) {
+		t.Errorf("unexpected loop: %v", expanded[loopJSONKey])
+	}
+}
+
+func TestUnknownActionError(t *testing.T) {
+	methods := (&SampleTestRunner{}).methods()
+	cases := []struct {
+		action string
+		err    string
+	}{
+		{"Helo", `unknown action "Helo", did you mean "Hello"? The methods of the service are Bye, Countdown, Echo, Hello, Profile, Sum, Wait`},
+		{"hello", `unknown action "hello", did you mean "Hello"? The methods of the service are Bye, Countdown, Echo, Hello, Profile, Sum, Wait`},
+		{"Goodbye", `unknown action "Goodbye". The methods of the service are Bye, Countdown, Echo, Hello, Profile, Sum, Wait`},
+	}
+	for _, c := range cases {
+		if err := unknownActionError(c.action, methods); err.Error() != c.err {
+			t.Errorf("unexpected error: %v, expected: %s", err, c.err)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"Hello", "Hello", 0},
+		{"Helo", "Hello", 1},
+		{"Hlelo", "Hello", 2},
+		{"", "Bye", 3},
+		{"kitten", "sitting", 3},
+		{"héllo", "hello", 1},
+	}
+	for _, c := range cases {
+		if distance := editDistance(c.a, c.b); distance != c.distance {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", c.a, c.b, distance, c.distance)
+		}
+	}
+}
+
+func TestLoadScenarioUnknownAction(t *testing.T) {
+	jsonPath := writeScenario(t, "[\n    {\"action\": \"Helo\"}\n]\n")
+	_, err := loadScenario(jsonPath, (&SampleTestRunner{}).methods())
+	expected := "the scenario is invalid:\n" + jsonPath + `:2:16: [0].action: unknown action "Helo", did you mean "Hello"? The methods of the service are Bye, Countdown, Echo, Hello, Profile, Sum, Wait`
+	if err == nil || err.Error() != expected {
+		t.Errorf("unexpected error: %v, expected: %s", err, expected)
+	}
+}
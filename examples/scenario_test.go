@@ -2,56 +2,143 @@ package examples
 
 import (
 	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
 	"testing"
 
 	"github.com/yoshd/protoc-gen-stest/examples/pb"
+	"github.com/yoshd/protoc-gen-stest/examples/server"
 
 	"google.golang.org/grpc"
 )
 
 var responseCompareFuncMap = map[string]*func(expectedResponse, response interface{}) error{}
 
+// target is the address of the Sample server started by TestMain.
+var target string
+
 func TestScenario(t *testing.T) {
-	target := "localhost:13009"
-	client, _ := grpc.Dial(target, grpc.WithInsecure())
-	defer client.Close()
-	sampleClient := pb.NewSampleClient(client)
-	testClient := pb.NewTestClient(sampleClient)
-	testClient.RunGRPCTest(
-		t,
-		"scenario/sample.json",
-		responseCompareFuncMap,
-	)
+	// compareFuncMap is used only by sample.json, because the other scenarios compare the responses by match.
+	cases := []struct {
+		name           string
+		file           string
+		configure      func(*pb.SampleTestRunner)
+		compareFuncMap map[string]*func(expectedResponse, response interface{}) error
+	}{
+		{name: "Sample", file: "scenario/sample.json", compareFuncMap: responseCompareFuncMap},
+		{name: "Partial", file: "scenario/partial.json", configure: func(r *pb.SampleTestRunner) { r.Match = "partial" }},
+		{name: "Capture", file: "scenario/capture.json"},
+		{name: "Metadata", file: "scenario/metadata.json"},
+		{name: "Error", file: "scenario/errors.json"},
+		{name: "Timeout", file: "scenario/timeout.json"},
+		{name: "Filter", file: "scenario/filter.json", configure: func(r *pb.SampleTestRunner) { r.Tags = "smoke,!slow" }},
+		{name: "Eventually", file: "scenario/eventually.json"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client, _ := grpc.Dial(target, grpc.WithInsecure())
+			defer client.Close()
+			sampleClient := pb.NewSampleClient(client)
+			testClient := pb.NewSampleTestRunner(sampleClient)
+			if c.configure != nil {
+				c.configure(testClient)
+			}
+			testClient.RunGRPCTest(
+				t,
+				c.file,
+				c.compareFuncMap,
+			)
+		})
+	}
+}
+
+// TestContinueOnFailure runs testdata/continue_on_failure.json, whose test cases fail on purpose, in a subprocess,
+// and checks the failures reported by continue_on_failure and the ContinueOnFailure field of the test runner.
+func TestContinueOnFailure(t *testing.T) {
+	if mode := os.Getenv("STEST_CONTINUE_ON_FAILURE"); mode != "" {
+		client, _ := grpc.Dial(target, grpc.WithInsecure())
+		defer client.Close()
+		testClient := pb.NewSampleTestRunner(pb.NewSampleClient(client))
+		testClient.ContinueOnFailure = mode == "true"
+		testClient.RunGRPCTest(t, "testdata/continue_on_failure.json", nil)
+		return
+	}
+	cases := []struct {
+		mode       string
+		expected   []string
+		unexpected []string
+	}{
+		{
+			mode: "false",
+			expected: []string{
+				"iteration 1 of 3: the error of the response of Bye is not as expected",
+				"iteration 2 of 3: the error of the response of Bye is not as expected",
+				"iteration 3 of 3: the error of the response of Bye is not as expected",
+				"0 of 3 iterations passed",
+				"iteration 1 of 2: the actual response of the Hello was not equal to the expected response",
+			},
+			unexpected: []string{"iteration 2 of 2", "of 2 iterations passed"},
+		},
+		{
+			mode: "true",
+			expected: []string{
+				"0 of 3 iterations passed",
+				"iteration 1 of 2: the actual response of the Hello was not equal to the expected response",
+				"iteration 2 of 2: the actual response of the Hello was not equal to the expected response",
+				"0 of 2 iterations passed",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run("ContinueOnFailure="+c.mode, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestContinueOnFailure$", "-test.v")
+			cmd.Env = append(os.Environ(), "STEST_CONTINUE_ON_FAILURE="+c.mode)
+			out, err := cmd.CombinedOutput()
+			if err == nil {
+				t.Fatalf("the failing scenario passed:\n%s", out)
+			}
+			for _, s := range c.expected {
+				if !strings.Contains(string(out), s) {
+					t.Errorf("the output does not have %q:\n%s", s, out)
+				}
+			}
+			for _, s := range c.unexpected {
+				if strings.Contains(string(out), s) {
+					t.Errorf("the output has %q:\n%s", s, out)
+				}
+			}
+		})
+	}
 }
 
+// setUp registers the functions to compare the responses.
+// The responses of the methods without a function are compared with proto.Equal.
 func setUp() {
 	helloResponseCompareFunc := func(expectedResponse, response interface{}) error {
-		if expectedResponse == nil || response == nil {
-			return nil
-		}
-		er := expectedResponse.(pb.HelloResponse)
-		r := response.(pb.HelloResponse)
+		er := expectedResponse.(*pb.HelloResponse)
+		r := response.(*pb.HelloResponse)
 		if er.ResMsg != r.ResMsg {
 			return errors.New("the actual response of the Hello was not equal to the expected response")
 		}
 		return nil
 	}
 	responseCompareFuncMap["Hello"] = &helloResponseCompareFunc
-	byeResponseCompareFunc := func(expectedResponse, response interface{}) error {
-		if expectedResponse == nil || response == nil {
-			return nil
-		}
-		er := expectedResponse.(pb.ByeResponse)
-		r := response.(pb.ByeResponse)
-		if er.ResMsg != r.ResMsg {
-			return errors.New("the actual response of the Bye was not equal to the expected response")
-		}
-		return nil
-	}
-	responseCompareFuncMap["Bye"] = &byeResponseCompareFunc
 }
 
 func TestMain(m *testing.M) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		panic(err)
+	}
+	s := grpc.NewServer()
+	pb.RegisterSampleServer(s, &server.Server{})
+	go s.Serve(lis)
+	target = lis.Addr().String()
+
 	setUp()
-	m.Run()
+	code := m.Run()
+	s.Stop()
+	os.Exit(code)
 }
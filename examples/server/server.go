@@ -0,0 +1,173 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/yoshd/protoc-gen-stest/examples/pb"
+)
+
+// warmUpCalls is the number of the calls of Bye which fail while the server is warming up.
+const warmUpCalls = 2
+
+// Server is the implementation of the Sample service used by the examples.
+type Server struct {
+	// warmCalls is the number of the calls of Bye with "warm".
+	warmCalls int32
+}
+
+// Hello always returns "Hello!".
+// It sends x-request-id in the header, and sends back x-tenant and x-trace-bin of the request in the trailer.
+func (s *Server) Hello(ctx context.Context, in *pb.HelloRequest) (*pb.HelloResponse, error) {
+	if err := grpc.SetHeader(ctx, metadata.Pairs("x-request-id", fmt.Sprintf("req-%d", time.Now().UnixNano()))); err != nil {
+		return nil, err
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	trailer := metadata.MD{}
+	for _, key := range []string{"x-tenant", "x-trace-bin"} {
+		if values := md.Get(key); len(values) > 0 {
+			trailer.Set(key, values...)
+		}
+	}
+	if err := grpc.SetTrailer(ctx, trailer); err != nil {
+		return nil, err
+	}
+	return &pb.HelloResponse{ResMsg: "Hello!"}, nil
+}
+
+// Bye returns "Bye!", InvalidArgument with the details of the bad request if the request message is "error",
+// or Unavailable with the delay to retry if the request message is "busy".
+// If the request message is "warm", it returns Unavailable for the first warmUpCalls calls, like a server warming up.
+func (s *Server) Bye(ctx context.Context, in *pb.ByeRequest) (*pb.ByeResponse, error) {
+	switch in.ReqMsg {
+	case "error":
+		st, err := status.New(codes.InvalidArgument, "invalid argument").WithDetails(
+			&errdetails.BadRequest{
+				FieldViolations: []*errdetails.BadRequest_FieldViolation{
+					{Field: "req_msg", Description: "must not be error"},
+				},
+			},
+			&errdetails.ErrorInfo{Reason: "INVALID_MESSAGE", Domain: "example.com"},
+		)
+		if err != nil {
+			return nil, err
+		}
+		return nil, st.Err()
+	case "busy":
+		st, err := status.New(codes.Unavailable, "the server is busy").WithDetails(
+			&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)},
+		)
+		if err != nil {
+			return nil, err
+		}
+		return nil, st.Err()
+	case "warm":
+		if atomic.AddInt32(&s.warmCalls, 1) <= warmUpCalls {
+			return nil, status.Error(codes.Unavailable, "the server is warming up")
+		}
+	}
+	return &pb.ByeResponse{ResMsg: "Bye!"}, nil
+}
+
+// Countdown sends the numbers from the requested count down to 1, and the number of the sent responses as x-count in the trailer.
+func (s *Server) Countdown(in *pb.CountdownRequest, stream pb.Sample_CountdownServer) error {
+	if in.Count <= 0 {
+		return status.Errorf(codes.InvalidArgument, "count must be positive")
+	}
+	for i := in.Count; i > 0; i-- {
+		if err := stream.Send(&pb.CountdownResponse{Count: i}); err != nil {
+			return err
+		}
+	}
+	stream.SetTrailer(metadata.Pairs("x-count", strconv.Itoa(int(in.Count))))
+	return nil
+}
+
+// Sum returns the sum and the max of the received values, or InvalidArgument if a negative value is received.
+// The max is not set if no value is received. The number of the received values is sent as x-count in the trailer.
+func (s *Server) Sum(stream pb.Sample_SumServer) error {
+	var sum int32
+	var max *int32
+	count := 0
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			stream.SetTrailer(metadata.Pairs("x-count", strconv.Itoa(count)))
+			return stream.SendAndClose(&pb.SumResponse{Sum: sum, Max: max})
+		}
+		if err != nil {
+			return err
+		}
+		if req.Value < 0 {
+			return status.Errorf(codes.InvalidArgument, "value must not be negative")
+		}
+		sum += req.Value
+		count++
+		if max == nil || req.Value > *max {
+			value := req.Value
+			max = &value
+		}
+	}
+}
+
+// Profile returns the profile of the requested name, which has the fixed addresses and labels and is updated at the current time.
+func (s *Server) Profile(ctx context.Context, in *pb.ProfileRequest) (*pb.ProfileResponse, error) {
+	return &pb.ProfileResponse{
+		Name: in.Name,
+		Address: &pb.ProfileResponse_Address{
+			City:    "Tokyo",
+			ZipCode: "100-0001",
+		},
+		PastAddresses: []*pb.ProfileResponse_Address{
+			{City: "Osaka", ZipCode: "530-0001"},
+			{City: "Fukuoka", ZipCode: "810-0001"},
+		},
+		Labels: map[string]string{
+			"team": "api",
+			"role": "owner",
+		},
+		UpdatedAt: timestamppb.Now(),
+	}, nil
+}
+
+// Wait returns after the requested duration, or DeadlineExceeded or Canceled if the context is done before that.
+func (s *Server) Wait(ctx context.Context, in *pb.WaitRequest) (*pb.WaitResponse, error) {
+	select {
+	case <-time.After(in.Duration.AsDuration()):
+		return &pb.WaitResponse{}, nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// Echo sends back each received message until the client closes the stream.
+// The number of the messages is sent as x-count in the trailer.
+func (s *Server) Echo(stream pb.Sample_EchoServer) error {
+	count := 0
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			stream.SetTrailer(metadata.Pairs("x-count", strconv.Itoa(count)))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&pb.EchoResponse{Msg: req.Msg}); err != nil {
+			return err
+		}
+		count++
+	}
+}
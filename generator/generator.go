@@ -7,17 +7,23 @@ import (
 )
 
 // GRPCCodeGenInfo defines the information to be rendered in the template of the GRPC test code.
+// GRPCClientType is the type of the client of the service, which is qualified with its package if the generated code
+// is in another package. If it is empty, the client is GRPCServiceName followed by "Client" in the same package.
 type GRPCCodeGenInfo struct {
 	Package         string
 	GRPCServiceName string
+	GRPCClientType  string
 	GRPCMethods     []GRPCMethod
 }
 
-// GRPCMethod defines the method name and the type string of the request and the type string of the response
+// GRPCMethod defines the method name and the type string of the request and the type string of the response.
+// ClientStreaming and ServerStreaming report whether the client and the server send a stream of messages.
 type GRPCMethod struct {
-	Name         string
-	RequestType  string
-	ResponseType string
+	Name            string
+	RequestType     string
+	ResponseType    string
+	ClientStreaming bool
+	ServerStreaming bool
 }
 
 // Validate validates that the field does not contain zero values.
@@ -45,14 +51,109 @@ func (grpcCodeGenInfo *GRPCCodeGenInfo) Validate() error {
 	return nil
 }
 
+// ClientType returns the type of the client of the service.
+func (grpcCodeGenInfo *GRPCCodeGenInfo) ClientType() string {
+	if grpcCodeGenInfo.GRPCClientType != "" {
+		return grpcCodeGenInfo.GRPCClientType
+	}
+	return grpcCodeGenInfo.GRPCServiceName + "Client"
+}
+
+// HasServerStreaming reports whether the service has at least one server-streaming method.
+func (grpcCodeGenInfo *GRPCCodeGenInfo) HasServerStreaming() bool {
+	for _, method := range grpcCodeGenInfo.GRPCMethods {
+		if method.ServerStreaming {
+			return true
+		}
+	}
+	return false
+}
+
+// HasClientStreaming reports whether the service has at least one client-streaming method.
+func (grpcCodeGenInfo *GRPCCodeGenInfo) HasClientStreaming() bool {
+	for _, method := range grpcCodeGenInfo.GRPCMethods {
+		if method.ClientStreaming {
+			return true
+		}
+	}
+	return false
+}
+
+// HasBidiStreaming reports whether the service has at least one bidirectional streaming method.
+func (grpcCodeGenInfo *GRPCCodeGenInfo) HasBidiStreaming() bool {
+	for _, method := range grpcCodeGenInfo.GRPCMethods {
+		if method.ClientStreaming && method.ServerStreaming {
+			return true
+		}
+	}
+	return false
+}
+
+// HasStreaming reports whether the service has at least one streaming method.
+func (grpcCodeGenInfo *GRPCCodeGenInfo) HasStreaming() bool {
+	return grpcCodeGenInfo.HasClientStreaming() || grpcCodeGenInfo.HasServerStreaming()
+}
+
+// GoImportPaths returns the import paths of the Go packages used by the code generated by GenerateGRPCTestCode.
+// The generated code has no import declarations, so that the imports are added together with the packages
+// of the requests and the responses by the caller.
+func (grpcCodeGenInfo *GRPCCodeGenInfo) GoImportPaths() []string {
+	importPaths := []string{"context", "fmt"}
+	if grpcCodeGenInfo.HasBidiStreaming() {
+		importPaths = append(importPaths, "errors")
+	}
+	if grpcCodeGenInfo.HasStreaming() {
+		importPaths = append(importPaths, "io")
+	}
+	importPaths = append(importPaths, "testing", "time")
+	for _, method := range grpcCodeGenInfo.GRPCMethods {
+		// The header and the trailer of the methods except bidirectional streaming ones are received with the call options.
+		if !method.ClientStreaming || !method.ServerStreaming {
+			importPaths = append(importPaths, "google.golang.org/grpc")
+			break
+		}
+	}
+	importPaths = append(importPaths, "google.golang.org/grpc/metadata")
+	// The responses of the streams are collected as proto.Message to be captured.
+	if grpcCodeGenInfo.HasServerStreaming() || grpcCodeGenInfo.HasBidiStreaming() {
+		importPaths = append(importPaths, "google.golang.org/protobuf/proto")
+	}
+	return importPaths
+}
+
+// GenerateGRPCTestHelperCode generates the helper code shared by the gRPC scenario test code of all the services in the package.
+func GenerateGRPCTestHelperCode(packageName string) (string, error) {
+	if packageName == "" {
+		return "", errors.New("packageName is not allowed empty")
+	}
+	templ, err := template.New(packageName).Parse(helperTemplate)
+	if err != nil {
+		return "", err
+	}
+	buf := bytes.Buffer{}
+	if err := templ.Execute(&buf, packageName); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
 // GenerateGRPCTestCode generates gRPC scenario test code.
 func GenerateGRPCTestCode(grpcCodeGenInfo GRPCCodeGenInfo) (string, error) {
+	return GenerateGRPCTestCodeFromTemplate(grpcCodeGenInfo, codeTemplate)
+}
+
+// GenerateGRPCTestCodeFromTemplate generates gRPC scenario test code from the given text/template instead of the default template.
+// The template is executed with *GRPCCodeGenInfo.
+func GenerateGRPCTestCodeFromTemplate(grpcCodeGenInfo GRPCCodeGenInfo, templateText string) (string, error) {
 	if err := grpcCodeGenInfo.Validate(); err != nil {
 		return "", err
 	}
-	templ, _ := template.New(grpcCodeGenInfo.GRPCServiceName).Parse(codeTemplate)
+	templ, err := template.New(grpcCodeGenInfo.GRPCServiceName).Parse(templateText)
+	if err != nil {
+		return "", err
+	}
 	buf := bytes.Buffer{}
-	if err := templ.Execute(&buf, grpcCodeGenInfo); err != nil {
+	if err := templ.Execute(&buf, &grpcCodeGenInfo); err != nil {
 		return "", err
 	}
 	return buf.String(), nil
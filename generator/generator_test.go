@@ -1,27 +1,32 @@
 package generator
 
 import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
 func TestGRPCCodeGenInfoValidateNoError(t *testing.T) {
 	assert := assert.New(t)
 	cases := []GRPCCodeGenInfo{
 		{
-			"package",
-			"ServiceName",
-			[]GRPCMethod{
+			Package:         "package",
+			GRPCServiceName: "ServiceName",
+			GRPCMethods: []GRPCMethod{
 				{
-					"Method1",
-					"Request",
-					"Response",
+					Name:         "Method1",
+					RequestType:  "Request",
+					ResponseType: "Response",
 				},
 				{
-					"Method2",
-					"Request",
-					"Response",
+					Name:         "Method2",
+					RequestType:  "Request",
+					ResponseType: "Response",
 				},
 			},
 		},
@@ -36,87 +41,87 @@ func TestGRPCCodeGenInfoValidateError(t *testing.T) {
 	assert := assert.New(t)
 	cases := []GRPCCodeGenInfo{
 		{
-			"",
-			"ServiceName",
-			[]GRPCMethod{
+			Package:         "",
+			GRPCServiceName: "ServiceName",
+			GRPCMethods: []GRPCMethod{
 				{
-					"Method1",
-					"Request",
-					"Response",
+					Name:         "Method1",
+					RequestType:  "Request",
+					ResponseType: "Response",
 				},
 				{
-					"Method2",
-					"Request",
-					"Response",
+					Name:         "Method2",
+					RequestType:  "Request",
+					ResponseType: "Response",
 				},
 			},
 		},
 		{
-			"package",
-			"",
-			[]GRPCMethod{
+			Package:         "package",
+			GRPCServiceName: "",
+			GRPCMethods: []GRPCMethod{
 				{
-					"Method1",
-					"Request",
-					"Response",
+					Name:         "Method1",
+					RequestType:  "Request",
+					ResponseType: "Response",
 				},
 				{
-					"Method2",
-					"Request",
-					"Response",
+					Name:         "Method2",
+					RequestType:  "Request",
+					ResponseType: "Response",
 				},
 			},
 		},
 		{
-			"package",
-			"ServiceName",
-			[]GRPCMethod{},
+			Package:         "package",
+			GRPCServiceName: "ServiceName",
+			GRPCMethods:     []GRPCMethod{},
 		},
 		{
-			"package",
-			"ServiceName",
-			[]GRPCMethod{
+			Package:         "package",
+			GRPCServiceName: "ServiceName",
+			GRPCMethods: []GRPCMethod{
 				{
-					"",
-					"Request",
-					"Response",
+					Name:         "",
+					RequestType:  "Request",
+					ResponseType: "Response",
 				},
 				{
-					"Method2",
-					"Request",
-					"Response",
+					Name:         "Method2",
+					RequestType:  "Request",
+					ResponseType: "Response",
 				},
 			},
 		},
 		{
-			"package",
-			"ServiceName",
-			[]GRPCMethod{
+			Package:         "package",
+			GRPCServiceName: "ServiceName",
+			GRPCMethods: []GRPCMethod{
 				{
-					"Method1",
-					"Request",
-					"Response",
+					Name:         "Method1",
+					RequestType:  "Request",
+					ResponseType: "Response",
 				},
 				{
-					"Method2",
-					"",
-					"Response",
+					Name:         "Method2",
+					RequestType:  "",
+					ResponseType: "Response",
 				},
 			},
 		},
 		{
-			"package",
-			"ServiceName",
-			[]GRPCMethod{
+			Package:         "package",
+			GRPCServiceName: "ServiceName",
+			GRPCMethods: []GRPCMethod{
 				{
-					"Method1",
-					"Request",
-					"Response",
+					Name:         "Method1",
+					RequestType:  "Request",
+					ResponseType: "Response",
 				},
 				{
-					"Method2",
-					"Request",
-					"",
+					Name:         "Method2",
+					RequestType:  "Request",
+					ResponseType: "",
 				},
 			},
 		},
@@ -128,246 +133,201 @@ func TestGRPCCodeGenInfoValidateError(t *testing.T) {
 }
 
 func TestGenerateGRPCTestCode(t *testing.T) {
-	assert := assert.New(t)
-	grpcCodeGenInfo := GRPCCodeGenInfo{
-		Package:         "pb",
-		GRPCServiceName: "TestService",
-		GRPCMethods: []GRPCMethod{
-			{
-				Name:         "Hello",
-				RequestType:  "HReq",
-				ResponseType: "HRes",
+	cases := []struct {
+		golden          string
+		grpcCodeGenInfo GRPCCodeGenInfo
+	}{
+		{
+			golden: "unary.golden",
+			grpcCodeGenInfo: GRPCCodeGenInfo{
+				Package:         "pb",
+				GRPCServiceName: "TestService",
+				GRPCMethods: []GRPCMethod{
+					{
+						Name:         "Hello",
+						RequestType:  "HReq",
+						ResponseType: "HRes",
+					},
+					{
+						Name:         "Bye",
+						RequestType:  "BReq",
+						ResponseType: "BRes",
+					},
+				},
 			},
-			{
-				Name:         "Bye",
-				RequestType:  "BReq",
-				ResponseType: "BRes",
+		},
+		{
+			golden: "server_streaming.golden",
+			grpcCodeGenInfo: GRPCCodeGenInfo{
+				Package:         "pb",
+				GRPCServiceName: "TestService",
+				GRPCMethods: []GRPCMethod{
+					{
+						Name:         "Hello",
+						RequestType:  "HReq",
+						ResponseType: "HRes",
+					},
+					{
+						Name:            "Watch",
+						RequestType:     "WReq",
+						ResponseType:    "WRes",
+						ServerStreaming: true,
+					},
+				},
+			},
+		},
+		{
+			golden: "client_streaming.golden",
+			grpcCodeGenInfo: GRPCCodeGenInfo{
+				Package:         "pb",
+				GRPCServiceName: "TestService",
+				GRPCMethods: []GRPCMethod{
+					{
+						Name:         "Hello",
+						RequestType:  "HReq",
+						ResponseType: "HRes",
+					},
+					{
+						Name:            "Upload",
+						RequestType:     "UReq",
+						ResponseType:    "URes",
+						ClientStreaming: true,
+					},
+				},
+			},
+		},
+		{
+			golden: "bidi_streaming.golden",
+			grpcCodeGenInfo: GRPCCodeGenInfo{
+				Package:         "pb",
+				GRPCServiceName: "TestService",
+				GRPCMethods: []GRPCMethod{
+					{
+						Name:         "Hello",
+						RequestType:  "HReq",
+						ResponseType: "HRes",
+					},
+					{
+						Name:            "Chat",
+						RequestType:     "CReq",
+						ResponseType:    "CRes",
+						ClientStreaming: true,
+						ServerStreaming: true,
+					},
+				},
+			},
+		},
+		{
+			golden: "qualified_types.golden",
+			grpcCodeGenInfo: GRPCCodeGenInfo{
+				Package:         "apiv1",
+				GRPCServiceName: "TestService",
+				GRPCMethods: []GRPCMethod{
+					{
+						Name:         "Ping",
+						RequestType:  "emptypb.Empty",
+						ResponseType: "Outer_Inner",
+					},
+				},
+			},
+		},
+		{
+			golden: "external_test_package.golden",
+			grpcCodeGenInfo: GRPCCodeGenInfo{
+				Package:         "apiv1_test",
+				GRPCServiceName: "TestService",
+				GRPCClientType:  "apiv1.TestServiceClient",
+				GRPCMethods: []GRPCMethod{
+					{
+						Name:         "Ping",
+						RequestType:  "apiv1.Request",
+						ResponseType: "apiv1.Response",
+					},
+				},
 			},
 		},
 	}
-	code, err := GenerateGRPCTestCode(grpcCodeGenInfo)
-	assert.Equal(expectedCode, code)
-	assert.NoError(err)
-}
-
-var expectedCode = `
-package pb
-
-import (
-	"context"
-	"encoding/json"
-	"errors"
-	"io/ioutil"
-	"reflect"
-	"testing"
-	"time"
-
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-)
-
-// TestServiceTestRunner is a runner to run the TestService service test.
-type TestServiceTestRunner struct {
-	Client TestServiceClient
-}
-
-// NewTestClient returns new TestServiceRunner.
-func NewTestClient(client TestServiceClient) *TestServiceTestRunner {
-	return &TestServiceTestRunner{
-		Client: client,
+	for _, c := range cases {
+		t.Run(c.golden, func(t *testing.T) {
+			assert := assert.New(t)
+			code, err := GenerateGRPCTestCode(c.grpcCodeGenInfo)
+			assert.NoError(err)
+			assertGolden(t, c.golden, code)
+		})
 	}
 }
 
-// RunGRPCTest sends a gPRC request according to the scenario written in the JSON file and tests the response.
-// compareFuncMap takes a gRPC method name as a key and value has a function that compares expected response and actual response and return an error.
-func (runner *TestServiceTestRunner) RunGRPCTest(t *testing.T, jsonPath string, compareFuncMap map[string]*func(expectedResponse, response interface{}) error) {
-	scenarioData, err := ioutil.ReadFile(jsonPath)
-	if err != nil {
-		panic(err)
-	}
-	var scenario []map[string]interface{}
-	json.Unmarshal(scenarioData, &scenario)
-	for _, testCase := range scenario {
-		ctx := context.Background()
-		runner.runTest(ctx, t, testCase, compareFuncMap)
+func TestGRPCCodeGenInfoGoImportPaths(t *testing.T) {
+	assert := assert.New(t)
+	grpcCodeGenInfo := &GRPCCodeGenInfo{
+		GRPCMethods: []GRPCMethod{
+			{Name: "Hello"},
+		},
 	}
-}
+	assert.NotContains(grpcCodeGenInfo.GoImportPaths(), "io")
+	assert.NotContains(grpcCodeGenInfo.GoImportPaths(), "google.golang.org/protobuf/proto")
 
-const (
-	actionJSONKey            = "action"
-	requestJSONKey           = "request"
-	expectedResponseJSONKey  = "expected_response"
-	errorExpectationJSONKey  = "error_expectation"
-	expectedErrorCodeJSONKey = "expected_error_code"
-	loopJSONKey              = "loop"
-	sleepJSONKey             = "sleep"
-	successRuleJSONKey       = "success_rule"
-	successRuleAll           = "all"
-	successRuleOnce          = "once"
-)
-
-func (runner *TestServiceTestRunner) runTest(ctx context.Context, t *testing.T, testCase map[string]interface{}, compareFuncMap map[string]*func(expectedResponse, response interface{}) error) {
-	var action string
-	if v, ok := testCase[actionJSONKey]; ok {
-		action = v.(string)
-	} else {
-		panic("Scenario JSON is invalid. Because action is required.")
-	}
-	f := func(t *testing.T) {
-		switch action {
-		case "Hello":
-			compareFunc := compareFuncMap["Hello"]
-			runner.testHello(ctx, t, testCase, compareFunc)
-		case "Bye":
-			compareFunc := compareFuncMap["Bye"]
-			runner.testBye(ctx, t, testCase, compareFunc)
-		}
-	}
-	t.Run(action, f)
-}
+	grpcCodeGenInfo.GRPCMethods = append(grpcCodeGenInfo.GRPCMethods, GRPCMethod{Name: "Upload", ClientStreaming: true})
+	assert.NotContains(grpcCodeGenInfo.GoImportPaths(), "google.golang.org/protobuf/proto")
+	grpcCodeGenInfo.GRPCMethods = grpcCodeGenInfo.GRPCMethods[:1]
 
-func (runner *TestServiceTestRunner) testHello(ctx context.Context, t *testing.T, testCase map[string]interface{}, compareFunc *func(expectedResponse, response interface{}) error) {
-	reqJSON, reqErr := json.Marshal(testCase[requestJSONKey])
-	if reqErr != nil {
-		panic(reqErr)
-	}
-	req := HReq{}
-	json.Unmarshal(reqJSON, &req)
+	grpcCodeGenInfo.GRPCMethods = append(grpcCodeGenInfo.GRPCMethods, GRPCMethod{Name: "Watch", ServerStreaming: true})
+	assert.Contains(grpcCodeGenInfo.GoImportPaths(), "io")
+	assert.NotContains(grpcCodeGenInfo.GoImportPaths(), "errors")
+	assert.Contains(grpcCodeGenInfo.GoImportPaths(), "google.golang.org/protobuf/proto")
 
-	loop := 1
-	if v, ok := testCase[loopJSONKey]; ok {
-		loop = int(v.(float64))
-	}
-FOR_LABEL:
-	for i := 1; i <= loop; i++ {
-		sleep := 0
-		if v, ok := testCase[sleepJSONKey]; ok {
-			sleep = int(v.(float64))
-		}
-		time.Sleep(time.Duration(sleep) * time.Second)
+	grpcCodeGenInfo.GRPCMethods = append(grpcCodeGenInfo.GRPCMethods, GRPCMethod{Name: "Chat", ClientStreaming: true, ServerStreaming: true})
+	assert.Contains(grpcCodeGenInfo.GoImportPaths(), "errors")
+	assert.Contains(grpcCodeGenInfo.GoImportPaths(), "google.golang.org/grpc")
 
-		res, err := runner.Client.Hello(ctx, &req)
+	grpcCodeGenInfo.GRPCMethods = grpcCodeGenInfo.GRPCMethods[2:]
+	assert.NotContains(grpcCodeGenInfo.GoImportPaths(), "google.golang.org/grpc")
+	assert.Contains(grpcCodeGenInfo.GoImportPaths(), "google.golang.org/grpc/metadata")
+}
 
-		errExpectation := false
-		if v, ok := testCase[errorExpectationJSONKey]; ok {
-			errExpectation = v.(bool)
-		}
-		if errExpectation {
-			errCodeF := testCase[expectedErrorCodeJSONKey].(float64)
-			errCodeU := uint32(errCodeF)
-			expectedErrCode := codes.Code(errCodeU)
-			if expectedErrCode != grpc.Code(err) {
-				t.Fatalf("the error code of the response of Hello is not as expected. Expected: %d, Actual: %d\n", expectedErrCode, grpc.Code(err))
-			}
-			break FOR_LABEL
-		} else {
-			resJSON, resErr := json.Marshal(testCase[expectedResponseJSONKey])
-			if resErr != nil {
-				panic(resErr)
-			}
-			expectedRes := HRes{}
-			json.Unmarshal(resJSON, &expectedRes)
-			successRule := successRuleAll
-			if v, ok := testCase[successRuleJSONKey]; ok {
-				successRule = v.(string)
-			}
-			var err error
-			if compareFunc != nil {
-				compare := *compareFunc
-				err = compare(expectedRes, *res)
-			} else {
-				if !reflect.DeepEqual(expectedRes, *res) {
-					err = errors.New("the actual response of the Hello was not equal to the expected response")
-				}
-			}
+func TestGenerateGRPCTestHelperCode(t *testing.T) {
+	assert := assert.New(t)
+	code, err := GenerateGRPCTestHelperCode("pb")
+	assert.NoError(err)
+	assertGolden(t, "helper.golden", code)
 
-			switch successRule {
-			case successRuleAll:
-				if err != nil {
-					t.Fatal(err.Error())
-				}
-			case successRuleOnce:
-				if i == loop && err != nil {
-					t.Fatal(err.Error())
-				}
-				if err == nil {
-					break FOR_LABEL
-				}
-			}
-		}
-	}
+	_, err = GenerateGRPCTestHelperCode("")
+	assert.Error(err)
 }
 
-func (runner *TestServiceTestRunner) testBye(ctx context.Context, t *testing.T, testCase map[string]interface{}, compareFunc *func(expectedResponse, response interface{}) error) {
-	reqJSON, reqErr := json.Marshal(testCase[requestJSONKey])
-	if reqErr != nil {
-		panic(reqErr)
-	}
-	req := BReq{}
-	json.Unmarshal(reqJSON, &req)
-
-	loop := 1
-	if v, ok := testCase[loopJSONKey]; ok {
-		loop = int(v.(float64))
+func TestGenerateGRPCTestCodeFromTemplate(t *testing.T) {
+	assert := assert.New(t)
+	grpcCodeGenInfo := GRPCCodeGenInfo{
+		Package:         "pb",
+		GRPCServiceName: "TestService",
+		GRPCMethods: []GRPCMethod{
+			{
+				Name:         "Hello",
+				RequestType:  "HReq",
+				ResponseType: "HRes",
+			},
+		},
 	}
-FOR_LABEL:
-	for i := 1; i <= loop; i++ {
-		sleep := 0
-		if v, ok := testCase[sleepJSONKey]; ok {
-			sleep = int(v.(float64))
-		}
-		time.Sleep(time.Duration(sleep) * time.Second)
-
-		res, err := runner.Client.Bye(ctx, &req)
+	code, err := GenerateGRPCTestCodeFromTemplate(grpcCodeGenInfo, "package {{.Package}}\n{{range .GRPCMethods}}// {{.Name}}\n{{end}}")
+	assert.NoError(err)
+	assert.Equal("package pb\n// Hello\n", code)
 
-		errExpectation := false
-		if v, ok := testCase[errorExpectationJSONKey]; ok {
-			errExpectation = v.(bool)
-		}
-		if errExpectation {
-			errCodeF := testCase[expectedErrorCodeJSONKey].(float64)
-			errCodeU := uint32(errCodeF)
-			expectedErrCode := codes.Code(errCodeU)
-			if expectedErrCode != grpc.Code(err) {
-				t.Fatalf("the error code of the response of Bye is not as expected. Expected: %d, Actual: %d\n", expectedErrCode, grpc.Code(err))
-			}
-			break FOR_LABEL
-		} else {
-			resJSON, resErr := json.Marshal(testCase[expectedResponseJSONKey])
-			if resErr != nil {
-				panic(resErr)
-			}
-			expectedRes := BRes{}
-			json.Unmarshal(resJSON, &expectedRes)
-			successRule := successRuleAll
-			if v, ok := testCase[successRuleJSONKey]; ok {
-				successRule = v.(string)
-			}
-			var err error
-			if compareFunc != nil {
-				compare := *compareFunc
-				err = compare(expectedRes, *res)
-			} else {
-				if !reflect.DeepEqual(expectedRes, *res) {
-					err = errors.New("the actual response of the Bye was not equal to the expected response")
-				}
-			}
+	_, err = GenerateGRPCTestCodeFromTemplate(grpcCodeGenInfo, "{{.Package")
+	assert.Error(err)
+}
 
-			switch successRule {
-			case successRuleAll:
-				if err != nil {
-					t.Fatal(err.Error())
-				}
-			case successRuleOnce:
-				if i == loop && err != nil {
-					t.Fatal(err.Error())
-				}
-				if err == nil {
-					break FOR_LABEL
-				}
-			}
+// assertGolden compares the generated code with testdata/<golden>.
+// Run "go test ./generator -update" to rewrite the golden files after changing the template.
+func assertGolden(t *testing.T, golden, code string) {
+	path := filepath.Join("testdata", golden)
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(code), 0644); err != nil {
+			t.Fatal(err)
 		}
 	}
+	expectedCode, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(expectedCode), code)
 }
-
-`
@@ -1,42 +1,114 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
-
-	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"path/filepath"
 
 	"github.com/yoshd/protoc-gen-stest/generator"
 	"github.com/yoshd/protoc-gen-stest/processor"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
 )
 
-var generateCodeFunc = func(packageName, serviceName string, methods []*descriptor.MethodDescriptorProto) string {
-	grpcMethods := make([]generator.GRPCMethod, len(methods))
-	for i, m := range methods {
-		reqType := m.GetInputType()[1:]
-		resType := m.GetOutputType()[1:]
+var generateCodeFunc = func(g *protogen.GeneratedFile, packageName string, file *protogen.File, service *protogen.Service, opts *processor.Options) error {
+	grpcMethods := make([]generator.GRPCMethod, len(service.Methods))
+	for i, m := range service.Methods {
 		grpcMethods[i] = generator.GRPCMethod{
-			Name:         m.GetName(),
-			RequestType:  reqType,
-			ResponseType: resType,
+			Name:            m.GoName,
+			ClientStreaming: m.Desc.IsStreamingClient(),
+			ServerStreaming: m.Desc.IsStreamingServer(),
 		}
 	}
 	grpcCodeGenInfo := generator.GRPCCodeGenInfo{
 		Package:         packageName,
-		GRPCServiceName: serviceName,
+		GRPCServiceName: service.GoName,
 		GRPCMethods:     grpcMethods,
 	}
-	code, err := generator.GenerateGRPCTestCode(grpcCodeGenInfo)
+	// The packages used by the default template are imported before the packages of the requests and the responses,
+	// so that they keep their names and the packages of the messages are renamed on conflicts instead.
+	if opts.Template == "" {
+		for _, importPath := range grpcCodeGenInfo.GoImportPaths() {
+			g.QualifiedGoIdent(protogen.GoIdent{GoImportPath: protogen.GoImportPath(importPath)})
+		}
+	}
+	// The client is generated by protoc-gen-go-grpc in the Go package of the file,
+	// which is imported if the code is generated in another package such as an external test package.
+	grpcCodeGenInfo.GRPCClientType = g.QualifiedGoIdent(protogen.GoIdent{GoName: service.GoName + "Client", GoImportPath: file.GoImportPath})
+	for i, m := range service.Methods {
+		grpcMethods[i].RequestType = g.QualifiedGoIdent(m.Input.GoIdent)
+		grpcMethods[i].ResponseType = g.QualifiedGoIdent(m.Output.GoIdent)
+	}
+	code, err := generateGRPCTestCode(grpcCodeGenInfo, opts)
+	if err != nil {
+		return err
+	}
+	g.P(code)
+	return nil
+}
+
+// generateGRPCTestCode generates the code from the template given by Options.Template, or from the default template if it is not given.
+func generateGRPCTestCode(grpcCodeGenInfo generator.GRPCCodeGenInfo, opts *processor.Options) (string, error) {
+	if opts.Template == "" {
+		return generator.GenerateGRPCTestCode(grpcCodeGenInfo)
+	}
+	codeTemplate, err := ioutil.ReadFile(opts.Template)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the template: %v", err)
+	}
+	return generator.GenerateGRPCTestCodeFromTemplate(grpcCodeGenInfo, string(codeTemplate))
+}
+
+var generateHelperCodeFunc = func(g *protogen.GeneratedFile, packageName string, opts *processor.Options) error {
+	code, err := generator.GenerateGRPCTestHelperCode(packageName)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	return code
+	g.P(code)
+	return nil
 }
 
+// main runs the plugin with protogen, which reads the CodeGeneratorRequest from stdin and writes the CodeGeneratorResponse to stdout.
+// The Go packages which protogen cannot resolve are mapped by processor.AddGoPackageMappings before protogen reads the request.
+// Invalid parameters and failures of the code generation are reported to protoc through CodeGeneratorResponse.Error,
+// and failures of the plugin protocol itself make the plugin exit with a non-zero code.
 func main() {
-	req, err := processor.ParseRequest(os.Stdin)
+	if err := run(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", filepath.Base(os.Args[0]), err)
+		os.Exit(1)
+	}
+}
+
+func run(r io.Reader, w io.Writer) error {
+	in, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	req := &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(in, req); err != nil {
+		return err
+	}
+	processor.AddGoPackageMappings(req)
+	opts := processor.NewOptions()
+	var res *pluginpb.CodeGeneratorResponse
+	gen, err := protogen.Options{
+		ParamFunc: opts.Set,
+	}.New(req)
+	if err != nil {
+		res = &pluginpb.CodeGeneratorResponse{Error: proto.String(err.Error())}
+	} else {
+		if err := processor.ProcessRequest(gen, opts, generateCodeFunc, generateHelperCodeFunc); err != nil {
+			gen.Error(err)
+		}
+		res = gen.Response()
+	}
+	out, err := proto.Marshal(res)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	res := processor.ProcessRequest(req, generateCodeFunc)
-	processor.EmitResponse(res)
+	_, err = w.Write(out)
+	return err
 }
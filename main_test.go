@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// runTestRequest runs the plugin with the request in the same way as protoc, and returns the response.
+func runTestRequest(t *testing.T, req *pluginpb.CodeGeneratorRequest) *pluginpb.CodeGeneratorResponse {
+	in, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := run(bytes.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	res := &pluginpb.CodeGeneratorResponse{}
+	if err := proto.Unmarshal(out.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestRunInvalidParameter(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range []struct{ parameter, err string }{
+		{"foo=bar", `unknown parameter "foo"`},
+		{"suffix=x", `invalid parameter "suffix=x": suffix must end with ".go"`},
+		{"helper=maybe", `invalid parameter "helper=maybe": helper must be true or false`},
+	} {
+		res := runTestRequest(t, &pluginpb.CodeGeneratorRequest{Parameter: proto.String(c.parameter)})
+		assert.Equal(c.err, res.GetError(), c.parameter)
+		assert.Empty(res.File, c.parameter)
+	}
+}
+
+// testMethod is a method of the services compiled by TestGeneratedCodeCompiles.
+type testMethod struct {
+	name                             string
+	clientStreaming, serverStreaming bool
+}
+
+// TestGeneratedCodeCompiles generates the code of the services with the kinds of methods into a package in the module,
+// and builds it with the go command, because the imports of the generated code depend on the kinds of the methods.
+// The messages are google.protobuf.StringValue, and the clients are declared as protoc-gen-go-grpc does.
+func TestGeneratedCodeCompiles(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is not found")
+	}
+	services := map[string][]testMethod{
+		"OnlyUnary":  {{name: "Get"}, {name: "Put"}},
+		"OnlyClient": {{name: "Upload", clientStreaming: true}},
+		"Mixed": {
+			{name: "Get"},
+			{name: "Watch", serverStreaming: true},
+			{name: "Upload", clientStreaming: true},
+			{name: "Chat", clientStreaming: true, serverStreaming: true},
+		},
+	}
+	for _, serviceName := range []string{"OnlyUnary", "OnlyClient", "Mixed"} {
+		t.Run(serviceName, func(t *testing.T) {
+			dir, err := os.MkdirTemp(".", "_compile")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			importPath := "github.com/yoshd/protoc-gen-stest/" + filepath.Base(dir)
+			methods := services[serviceName]
+
+			service := &descriptorpb.ServiceDescriptorProto{Name: proto.String(serviceName)}
+			for _, m := range methods {
+				service.Method = append(service.Method, &descriptorpb.MethodDescriptorProto{
+					Name:            proto.String(m.name),
+					InputType:       proto.String(".google.protobuf.StringValue"),
+					OutputType:      proto.String(".google.protobuf.StringValue"),
+					ClientStreaming: proto.Bool(m.clientStreaming),
+					ServerStreaming: proto.Bool(m.serverStreaming),
+				})
+			}
+			res := runTestRequest(t, &pluginpb.CodeGeneratorRequest{
+				FileToGenerate: []string{"compile.proto"},
+				Parameter:      proto.String("paths=source_relative"),
+				ProtoFile: []*descriptorpb.FileDescriptorProto{
+					protodesc.ToFileDescriptorProto(wrapperspb.File_google_protobuf_wrappers_proto),
+					{
+						Name:       proto.String("compile.proto"),
+						Package:    proto.String("compile"),
+						Syntax:     proto.String("proto3"),
+						Dependency: []string{"google/protobuf/wrappers.proto"},
+						Options:    &descriptorpb.FileOptions{GoPackage: proto.String(importPath + ";compile")},
+						Service:    []*descriptorpb.ServiceDescriptorProto{service},
+					},
+				},
+			})
+			if res.Error != nil {
+				t.Fatal(res.GetError())
+			}
+			for _, f := range res.File {
+				if err := os.WriteFile(filepath.Join(dir, f.GetName()), []byte(f.GetContent()), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.WriteFile(filepath.Join(dir, "client.go"), []byte(clientCode(serviceName, methods)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			out, err := exec.Command("go", "build", "./"+filepath.Base(dir)).CombinedOutput()
+			assert.NoError(t, err, string(out))
+		})
+	}
+}
+
+// clientCode returns the client of the service declared in the same way as protoc-gen-go-grpc.
+func clientCode(serviceName string, methods []testMethod) string {
+	var b strings.Builder
+	b.WriteString("package compile\n\nimport (\n\t\"context\"\n\n\t\"google.golang.org/grpc\"\n\t\"google.golang.org/protobuf/types/known/wrapperspb\"\n)\n\n")
+	fmt.Fprintf(&b, "type %sClient interface {\n", serviceName)
+	for _, m := range methods {
+		stream := serviceName + "_" + m.name + "Client"
+		switch {
+		case !m.clientStreaming && !m.serverStreaming:
+			fmt.Fprintf(&b, "\t%s(ctx context.Context, in *wrapperspb.StringValue, opts ...grpc.CallOption) (*wrapperspb.StringValue, error)\n", m.name)
+		case !m.clientStreaming:
+			fmt.Fprintf(&b, "\t%s(ctx context.Context, in *wrapperspb.StringValue, opts ...grpc.CallOption) (%s, error)\n", m.name, stream)
+		default:
+			fmt.Fprintf(&b, "\t%s(ctx context.Context, opts ...grpc.CallOption) (%s, error)\n", m.name, stream)
+		}
+	}
+	b.WriteString("}\n")
+	for _, m := range methods {
+		stream := serviceName + "_" + m.name + "Client"
+		switch {
+		case m.clientStreaming && m.serverStreaming:
+			fmt.Fprintf(&b, "\ntype %s interface {\n\tSend(*wrapperspb.StringValue) error\n\tRecv() (*wrapperspb.StringValue, error)\n\tgrpc.ClientStream\n}\n", stream)
+		case m.serverStreaming:
+			fmt.Fprintf(&b, "\ntype %s interface {\n\tRecv() (*wrapperspb.StringValue, error)\n\tgrpc.ClientStream\n}\n", stream)
+		case m.clientStreaming:
+			fmt.Fprintf(&b, "\ntype %s interface {\n\tSend(*wrapperspb.StringValue) error\n\tCloseAndRecv() (*wrapperspb.StringValue, error)\n\tgrpc.ClientStream\n}\n", stream)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"go/token"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// AddGoPackageMappings adds the M<proto file>=<import path>;<package name> parameters to the request for the files whose Go package
+// cannot be resolved by protogen, so that such files are generated in the same way as the older protoc-gen-go.
+// The import path falls back to the directory of the .proto file, and the package name falls back to the proto package,
+// and then to the base name of the .proto file. The M parameters given to the plugin take precedence over them.
+// It must be called before protogen.Options.New.
+func AddGoPackageMappings(req *pluginpb.CodeGeneratorRequest) {
+	mapped := make(map[string]bool)
+	var params []string
+	if p := req.GetParameter(); p != "" {
+		params = strings.Split(p, ",")
+	}
+	for _, param := range params {
+		if strings.HasPrefix(param, "M") {
+			mapped[strings.SplitN(param[1:], "=", 2)[0]] = true
+		}
+	}
+	for _, f := range req.GetProtoFile() {
+		if mapped[f.GetName()] {
+			continue
+		}
+		importPath, packageName := goPackageOption(f.GetOptions().GetGoPackage())
+		if strings.ContainsAny(importPath, "./") {
+			continue
+		}
+		if importPath == "" {
+			importPath = path.Dir(f.GetName())
+		}
+		// protogen requires an import path which contains '.' or '/'.
+		if !strings.ContainsAny(importPath, "./") {
+			importPath = "./" + importPath
+		}
+		switch {
+		case packageName != "":
+		case f.GetPackage() != "":
+			packageName = f.GetPackage()
+		default:
+			packageName = strings.TrimSuffix(path.Base(f.GetName()), path.Ext(f.GetName()))
+		}
+		params = append(params, "M"+f.GetName()+"="+importPath+";"+cleanPackageName(packageName))
+	}
+	if len(params) > 0 {
+		req.Parameter = proto.String(strings.Join(params, ","))
+	}
+}
+
+// goPackageOption splits the go_package option into the Go import path and the Go package name in the same way as protoc-gen-go.
+// For example, "github.com/acme/api/v1;apiv1" is split into "github.com/acme/api/v1" and "apiv1",
+// and "github.com/acme/api/v1" is split into "github.com/acme/api/v1" and "v1".
+// An option without a slash such as "pb" has no import path.
+func goPackageOption(opt string) (importPath, packageName string) {
+	if i := strings.Index(opt, ";"); i >= 0 {
+		return opt[:i], opt[i+1:]
+	}
+	if i := strings.LastIndex(opt, "/"); i >= 0 {
+		return opt, opt[i+1:]
+	}
+	return "", opt
+}
+
+// cleanPackageName converts the name into a valid Go package name in the same way as protoc-gen-go.
+// Invalid characters are replaced with '_', and '_' is prepended to a Go keyword or a name which does not start with a letter.
+func cleanPackageName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	r, _ := utf8.DecodeRuneInString(name)
+	if token.Lookup(name).IsKeyword() || !unicode.IsLetter(r) {
+		return "_" + name
+	}
+	return name
+}
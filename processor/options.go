@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+const defaultSuffix = "_scenariotest.go"
+
+// Options defines the plugin parameters given by protoc with --stest_opt or --stest_out=<params>:<dir>.
+// paths, module and M<proto file>=<import path> are handled by protogen in the same way as protoc-gen-go.
+type Options struct {
+	// Suffix is appended to the snake-cased service name to make the output file name.
+	Suffix string
+	// Package overrides the Go package name of the generated code. The code is generated in the directory of the Go package
+	// of the .proto file, so it must be the name of the package, or the name followed by "_test" for an external test package.
+	Package string
+	// IncludeServices are the services to generate. If it is empty, all the services are generated.
+	IncludeServices []string
+	// ExcludeServices are the services not to generate.
+	ExcludeServices []string
+	// Template is the path to a text/template file used instead of the default template.
+	Template string
+	// Helper overrides whether the helper code shared by the services is generated. If it is nil, the helper code is generated
+	// only with the default template, because a custom template may declare the same identifiers.
+	Helper *bool
+}
+
+// NewOptions returns Options with the default values.
+func NewOptions() *Options {
+	return &Options{
+		Suffix: defaultSuffix,
+	}
+}
+
+// Set sets the value of the plugin parameter. It is used as protogen.Options.ParamFunc,
+// which is called for each key=value pair not handled by protogen itself.
+// include_service and exclude_service can be given more than once.
+func (opts *Options) Set(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("invalid parameter %q: the value is required", name)
+	}
+	switch name {
+	case "suffix":
+		if !strings.HasSuffix(value, ".go") {
+			return fmt.Errorf("invalid parameter %q: suffix must end with \".go\"", name+"="+value)
+		}
+		opts.Suffix = value
+	case "package":
+		opts.Package = value
+	case "include_service":
+		opts.IncludeServices = append(opts.IncludeServices, value)
+	case "exclude_service":
+		opts.ExcludeServices = append(opts.ExcludeServices, value)
+	case "template":
+		opts.Template = value
+	case "helper":
+		helper, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid parameter %q: helper must be true or false", name+"="+value)
+		}
+		opts.Helper = &helper
+	default:
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+	return nil
+}
+
+// goPackage returns the Go package name and the Go import path of the code generated for the file.
+// An external test package has the import path of the package of the file followed by "_test" like the go command,
+// so that the messages and the client of the package of the file are qualified with the package.
+func (opts *Options) goPackage(f *protogen.File) (protogen.GoPackageName, protogen.GoImportPath, error) {
+	switch opts.Package {
+	case "", string(f.GoPackageName):
+		return f.GoPackageName, f.GoImportPath, nil
+	case string(f.GoPackageName) + "_test":
+		if !strings.HasSuffix(opts.Suffix, "_test.go") {
+			return "", "", fmt.Errorf("package %s needs a suffix ending with \"_test.go\"", opts.Package)
+		}
+		return protogen.GoPackageName(opts.Package), f.GoImportPath + "_test", nil
+	}
+	return "", "", fmt.Errorf("package %s must be %s or %s_test, because the code is generated in the directory of the Go package %s", opts.Package, f.GoPackageName, f.GoPackageName, f.GoImportPath)
+}
+
+// generatesHelper reports whether the helper code is generated according to Helper and Template.
+func (opts *Options) generatesHelper() bool {
+	if opts.Helper != nil {
+		return *opts.Helper
+	}
+	return opts.Template == ""
+}
+
+// generates reports whether the service is generated according to IncludeServices and ExcludeServices.
+// A service can be specified by either its name or its fully-qualified name.
+func (opts *Options) generates(service *protogen.Service) bool {
+	names := []string{string(service.Desc.Name()), string(service.Desc.FullName())}
+	if len(opts.IncludeServices) > 0 && !containsAny(opts.IncludeServices, names) {
+		return false
+	}
+	return !containsAny(opts.ExcludeServices, names)
+}
+
+func containsAny(list, names []string) bool {
+	for _, l := range list {
+		for _, name := range names {
+			if l == name {
+				return true
+			}
+		}
+	}
+	return false
+}
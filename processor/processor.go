@@ -1,63 +1,77 @@
 package processor
 
 import (
-	"io"
-	"io/ioutil"
-	"os"
+	"errors"
+	"fmt"
+	"path"
 	"strings"
 	"unicode"
 
-	"github.com/golang/protobuf/proto"
-	descriptor "github.com/golang/protobuf/protoc-gen-go/descriptor"
-	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
 )
 
-// ParseRequest parses the input and returns the CodeGeneratorRequest of protoc.
-func ParseRequest(r io.Reader) (*plugin.CodeGeneratorRequest, error) {
-	buf, err := ioutil.ReadAll(r)
-	if err != nil {
-		return nil, err
-	}
-	var req plugin.CodeGeneratorRequest
-	if err = proto.Unmarshal(buf, &req); err != nil {
-		return nil, err
-	}
-	return &req, nil
-}
+// helperFileBaseName is the base name of the output file of the helper code, which is followed by Options.Suffix.
+const helperFileBaseName = "stest"
 
-// ProcessRequest processes the request and returns a response to generate the code.
-func ProcessRequest(req *plugin.CodeGeneratorRequest, genCodeFunc func(packageName, serviceName string, methods []*descriptor.MethodDescriptorProto) string) *plugin.CodeGeneratorResponse {
-	files := make(map[string]*descriptor.FileDescriptorProto)
-	for _, f := range req.ProtoFile {
-		files[f.GetName()] = f
-	}
-	var res plugin.CodeGeneratorResponse
-	for _, fname := range req.FileToGenerate {
-		f := files[fname]
-		for _, service := range f.GetService() {
-			packageName := f.GetOptions().GetGoPackage()
-			serviceName := service.GetName()
-			methods := service.GetMethod()
-			genCode := genCodeFunc(packageName, serviceName, methods)
-			serviceNameSnakeCase := toSnakeCase(service.GetName())
-			outputFname := serviceNameSnakeCase + "_scenariotest.go"
-			res.File = append(res.File, &plugin.CodeGeneratorResponse_File{
-				Name:    proto.String(outputFname),
-				Content: proto.String(genCode),
-			})
+// supportedFeatures are the features of protobuf advertised to protoc.
+// The code does not depend on how the fields of the messages are declared, because they are handled by the code generated by protoc-gen-go.
+const supportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL | pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS)
+
+// ProcessRequest generates the code of the services in the files to generate of the request.
+// genCodeFunc writes the code of each service in the file to the generated file, and genHelperCodeFunc writes the helper code shared by
+// the services in a Go package, which is generated once per output directory unless it is disabled by the options.
+// The output files are placed in the same directory as the .pb.go files generated by protoc-gen-go.
+// If the generation fails, the failures of all the files and services are collected and returned,
+// which are set to CodeGeneratorResponse.Error instead of the files by protogen.
+// It also advertises the support of proto3 optional fields and editions, so that protoc runs the plugin on such files.
+func ProcessRequest(gen *protogen.Plugin, opts *Options, genCodeFunc func(g *protogen.GeneratedFile, packageName string, file *protogen.File, service *protogen.Service, opts *Options) error, genHelperCodeFunc func(g *protogen.GeneratedFile, packageName string, opts *Options) error) error {
+	gen.SupportedFeatures = supportedFeatures
+	gen.SupportedEditionsMinimum = descriptorpb.Edition_EDITION_PROTO2
+	gen.SupportedEditionsMaximum = descriptorpb.Edition_EDITION_2023
+	var errs []string
+	helperFiles := make(map[string]bool)
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+		packageName, importPath, err := opts.goPackage(f)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", f.Desc.Path(), err))
+			continue
+		}
+		outputDir := path.Dir(f.GeneratedFilenamePrefix)
+		for _, service := range f.Services {
+			if !opts.generates(service) {
+				continue
+			}
+			if opts.generatesHelper() && toSnakeCase(service.GoName) == helperFileBaseName {
+				errs = append(errs, fmt.Sprintf("%s: service %s: the output file %s is used by the helper code, so give helper=false with a custom template or exclude the service", f.Desc.Path(), service.GoName, helperFileBaseName+opts.Suffix))
+				continue
+			}
+			g := gen.NewGeneratedFile(path.Join(outputDir, toSnakeCase(service.GoName)+opts.Suffix), importPath)
+			if err := genCodeFunc(g, string(packageName), f, service, opts); err != nil {
+				g.Skip()
+				errs = append(errs, fmt.Sprintf("%s: service %s: %v", f.Desc.Path(), service.GoName, err))
+				continue
+			}
+			helperFname := path.Join(outputDir, helperFileBaseName+opts.Suffix)
+			if !opts.generatesHelper() || helperFiles[helperFname] {
+				continue
+			}
+			helperFiles[helperFname] = true
+			g = gen.NewGeneratedFile(helperFname, importPath)
+			if err := genHelperCodeFunc(g, string(packageName), opts); err != nil {
+				g.Skip()
+				errs = append(errs, fmt.Sprintf("%s: %v", f.Desc.Path(), err))
+			}
 		}
 	}
-	return &res
-}
-
-// EmitResponse returns the response of protoc
-func EmitResponse(res *plugin.CodeGeneratorResponse) error {
-	buf, err := proto.Marshal(res)
-	if err != nil {
-		return err
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
 	}
-	_, err = os.Stdout.Write(buf)
-	return err
+	return nil
 }
 
 func toSnakeCase(str string) (snakeCaseStr string) {
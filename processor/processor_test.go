@@ -0,0 +1,352 @@
+package processor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func newTestRequest() *pluginpb.CodeGeneratorRequest {
+	return &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"a.proto", "b.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			{
+				Name:    proto.String("a.proto"),
+				Package: proto.String("acme.v1"),
+				Options: &descriptorpb.FileOptions{GoPackage: proto.String("github.com/acme/api/pb")},
+				Service: []*descriptorpb.ServiceDescriptorProto{
+					{Name: proto.String("FooService")},
+				},
+			},
+			{
+				Name:    proto.String("b.proto"),
+				Package: proto.String("acme.v1"),
+				Options: &descriptorpb.FileOptions{GoPackage: proto.String("github.com/acme/api/pb")},
+				Service: []*descriptorpb.ServiceDescriptorProto{
+					{Name: proto.String("BarService")},
+					{Name: proto.String("BazService")},
+				},
+			},
+		},
+	}
+}
+
+// processTestRequest processes the request with protogen in the same way as main, and returns the response.
+func processTestRequest(t *testing.T, req *pluginpb.CodeGeneratorRequest, genCodeFunc func(g *protogen.GeneratedFile, packageName string, file *protogen.File, service *protogen.Service, opts *Options) error) *pluginpb.CodeGeneratorResponse {
+	AddGoPackageMappings(req)
+	opts := NewOptions()
+	gen, err := protogen.Options{ParamFunc: opts.Set}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ProcessRequest(gen, opts, genCodeFunc, genHelperCodeFunc); err != nil {
+		gen.Error(err)
+	}
+	return gen.Response()
+}
+
+func genCodeFunc(g *protogen.GeneratedFile, packageName string, file *protogen.File, service *protogen.Service, opts *Options) error {
+	g.P("package ", packageName)
+	g.P()
+	g.P("type ", service.GoName, "TestRunner struct{}")
+	return nil
+}
+
+func genHelperCodeFunc(g *protogen.GeneratedFile, packageName string, opts *Options) error {
+	g.P("package ", packageName)
+	return nil
+}
+
+func TestProcessRequest(t *testing.T) {
+	assert := assert.New(t)
+	res := processTestRequest(t, newTestRequest(), genCodeFunc)
+	assert.Nil(res.Error)
+	assert.Equal(supportedFeatures, res.GetSupportedFeatures())
+	assert.Equal(int32(descriptorpb.Edition_EDITION_PROTO2), res.GetMinimumEdition())
+	assert.Equal(int32(descriptorpb.Edition_EDITION_2023), res.GetMaximumEdition())
+	assert.Len(res.File, 4)
+	assert.Equal("github.com/acme/api/pb/foo_service_scenariotest.go", res.File[0].GetName())
+	assert.Equal("package pb\n\ntype FooServiceTestRunner struct{}\n", res.File[0].GetContent())
+	assert.Equal("github.com/acme/api/pb/stest_scenariotest.go", res.File[1].GetName())
+	assert.Equal("package pb\n", res.File[1].GetContent())
+	assert.Equal("github.com/acme/api/pb/bar_service_scenariotest.go", res.File[2].GetName())
+	assert.Equal("github.com/acme/api/pb/baz_service_scenariotest.go", res.File[3].GetName())
+}
+
+func TestProcessRequestError(t *testing.T) {
+	assert := assert.New(t)
+	failingGenCodeFunc := func(g *protogen.GeneratedFile, packageName string, file *protogen.File, service *protogen.Service, opts *Options) error {
+		if service.GoName == "FooService" {
+			return genCodeFunc(g, packageName, file, service, opts)
+		}
+		return errors.New("failed")
+	}
+	res := processTestRequest(t, newTestRequest(), failingGenCodeFunc)
+	assert.Equal("b.proto: service BarService: failed\nb.proto: service BazService: failed", res.GetError())
+	assert.Empty(res.File)
+}
+
+func TestProcessRequestWithOptions(t *testing.T) {
+	assert := assert.New(t)
+	req := newTestRequest()
+	req.Parameter = proto.String("paths=source_relative,package=pb_test,suffix=_scenario_test.go,exclude_service=BazService")
+	res := processTestRequest(t, req, genCodeFunc)
+	assert.Nil(res.Error)
+	assert.Len(res.File, 3)
+	assert.Equal("foo_service_scenario_test.go", res.File[0].GetName())
+	assert.Equal("package pb_test\n\ntype FooServiceTestRunner struct{}\n", res.File[0].GetContent())
+	assert.Equal("stest_scenario_test.go", res.File[1].GetName())
+	assert.Equal("package pb_test\n", res.File[1].GetContent())
+	assert.Equal("bar_service_scenario_test.go", res.File[2].GetName())
+}
+
+func TestProcessRequestExternalTestPackage(t *testing.T) {
+	assert := assert.New(t)
+	req := newTestRequest()
+	req.FileToGenerate = []string{"a.proto"}
+	req.Parameter = proto.String("package=pb_test,suffix=_scenario_test.go")
+	qualifyingGenCodeFunc := func(g *protogen.GeneratedFile, packageName string, file *protogen.File, service *protogen.Service, opts *Options) error {
+		g.P("package ", packageName)
+		g.P()
+		g.P("var client ", protogen.GoIdent{GoName: service.GoName + "Client", GoImportPath: file.GoImportPath})
+		return nil
+	}
+	res := processTestRequest(t, req, qualifyingGenCodeFunc)
+	assert.Nil(res.Error)
+	assert.Contains(res.File[0].GetContent(), "pb \"github.com/acme/api/pb\"")
+	assert.Contains(res.File[0].GetContent(), "var client pb.FooServiceClient")
+}
+
+func TestProcessRequestInvalidPackage(t *testing.T) {
+	assert := assert.New(t)
+	req := newTestRequest()
+	req.FileToGenerate = []string{"a.proto"}
+	req.Parameter = proto.String("package=scenario")
+	res := processTestRequest(t, req, genCodeFunc)
+	assert.Equal("a.proto: package scenario must be pb or pb_test, because the code is generated in the directory of the Go package \"github.com/acme/api/pb\"", res.GetError())
+
+	req.Parameter = proto.String("package=pb_test")
+	res = processTestRequest(t, req, genCodeFunc)
+	assert.Equal("a.proto: package pb_test needs a suffix ending with \"_test.go\"", res.GetError())
+}
+
+func TestProcessRequestHelperPerPackage(t *testing.T) {
+	assert := assert.New(t)
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"acme/v1/foo.proto", "acme/v1/bar.proto", "acme/v2/foo.proto"},
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			{
+				Name:    proto.String("acme/v1/foo.proto"),
+				Package: proto.String("acme.v1"),
+				Options: &descriptorpb.FileOptions{GoPackage: proto.String("github.com/acme/api/v1")},
+				Service: []*descriptorpb.ServiceDescriptorProto{{Name: proto.String("Foo")}},
+			},
+			{
+				Name:    proto.String("acme/v1/bar.proto"),
+				Package: proto.String("acme.v1"),
+				Options: &descriptorpb.FileOptions{GoPackage: proto.String("github.com/acme/api/v1")},
+				Service: []*descriptorpb.ServiceDescriptorProto{{Name: proto.String("Bar")}},
+			},
+			{
+				Name:    proto.String("acme/v2/foo.proto"),
+				Package: proto.String("acme.v2"),
+				Options: &descriptorpb.FileOptions{GoPackage: proto.String("github.com/acme/api/v2")},
+				Service: []*descriptorpb.ServiceDescriptorProto{{Name: proto.String("Foo")}},
+			},
+		},
+		Parameter: proto.String("module=github.com/acme/api"),
+	}
+	res := processTestRequest(t, req, genCodeFunc)
+	assert.Nil(res.Error)
+	var names []string
+	for _, f := range res.File {
+		names = append(names, f.GetName())
+	}
+	assert.Equal([]string{
+		"v1/foo_scenariotest.go",
+		"v1/stest_scenariotest.go",
+		"v1/bar_scenariotest.go",
+		"v2/foo_scenariotest.go",
+		"v2/stest_scenariotest.go",
+	}, names)
+}
+
+func TestProcessRequestHelperOption(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		param string
+		names []string
+	}{
+		{"paths=source_relative", []string{"foo_service_scenariotest.go", "stest_scenariotest.go"}},
+		{"paths=source_relative,template=custom.tmpl", []string{"foo_service_scenariotest.go"}},
+		{"paths=source_relative,template=custom.tmpl,helper=true", []string{"foo_service_scenariotest.go", "stest_scenariotest.go"}},
+		{"paths=source_relative,helper=false", []string{"foo_service_scenariotest.go"}},
+	}
+	for _, c := range cases {
+		req := newTestRequest()
+		req.FileToGenerate = []string{"a.proto"}
+		req.Parameter = proto.String(c.param)
+		res := processTestRequest(t, req, genCodeFunc)
+		assert.Nil(res.Error, c.param)
+		var names []string
+		for _, f := range res.File {
+			names = append(names, f.GetName())
+		}
+		assert.Equal(c.names, names, c.param)
+	}
+}
+
+func TestProcessRequestHelperFileConflict(t *testing.T) {
+	assert := assert.New(t)
+	req := newTestRequest()
+	req.FileToGenerate = []string{"a.proto"}
+	req.ProtoFile[0].Service = append(req.ProtoFile[0].Service, &descriptorpb.ServiceDescriptorProto{Name: proto.String("Stest")})
+	res := processTestRequest(t, req, genCodeFunc)
+	assert.Equal("a.proto: service Stest: the output file stest_scenariotest.go is used by the helper code, so give helper=false with a custom template or exclude the service", res.GetError())
+
+	req.Parameter = proto.String("paths=source_relative,template=custom.tmpl")
+	res = processTestRequest(t, req, genCodeFunc)
+	assert.Nil(res.Error)
+	assert.Len(res.File, 2)
+	assert.Equal("stest_scenariotest.go", res.File[1].GetName())
+	assert.Equal("package pb\n\ntype StestTestRunner struct{}\n", res.File[1].GetContent())
+}
+
+func TestGoPackage(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		protoFile          string
+		protoPackage       string
+		goPackage          string
+		parameter          string
+		expectedImportPath string
+		expectedPackage    string
+		expectedPrefix     string
+	}{
+		{"sample.proto", "", "pb", "", ".", "pb", "sample"},
+		{"sample.proto", "", "github.com/acme/api/v1;apiv1", "", "github.com/acme/api/v1", "apiv1", "github.com/acme/api/v1/sample"},
+		{"sample.proto", "", "github.com/acme/api/v1", "", "github.com/acme/api/v1", "v1", "github.com/acme/api/v1/sample"},
+		{"sample.proto", "", "github.com/acme/go-api", "", "github.com/acme/go-api", "go_api", "github.com/acme/go-api/sample"},
+		{"sample.proto", "acme.v1", "", "", ".", "acme_v1", "sample"},
+		{"sample.proto", "", "", "", ".", "sample", "sample"},
+		{"api/type.proto", "", "", "", "./api", "_type", "api/type"},
+		{"api/type.proto", "", "", "paths=source_relative", "./api", "_type", "api/type"},
+		{"sample.proto", "acme.v1", "pb", "Msample.proto=github.com/acme/api/v2", "github.com/acme/api/v2", "pb", "github.com/acme/api/v2/sample"},
+		{"sample.proto", "acme.v1", "pb", "Msample.proto=github.com/acme/api/v2;apiv2", "github.com/acme/api/v2", "apiv2", "github.com/acme/api/v2/sample"},
+		{"sample.proto", "acme.v1", "pb", "Mother.proto=github.com/acme/api/v2", ".", "pb", "sample"},
+	}
+	for _, c := range cases {
+		req := &pluginpb.CodeGeneratorRequest{
+			FileToGenerate: []string{c.protoFile},
+			ProtoFile: []*descriptorpb.FileDescriptorProto{
+				{
+					Name:    proto.String(c.protoFile),
+					Package: proto.String(c.protoPackage),
+					Options: &descriptorpb.FileOptions{GoPackage: proto.String(c.goPackage)},
+				},
+			},
+			Parameter: proto.String(c.parameter),
+		}
+		AddGoPackageMappings(req)
+		gen, err := protogen.Options{ParamFunc: NewOptions().Set}.New(req)
+		if !assert.NoError(err, c) {
+			continue
+		}
+		f := gen.Files[0]
+		assert.Equal(c.expectedImportPath, string(f.GoImportPath), c)
+		assert.Equal(c.expectedPackage, string(f.GoPackageName), c)
+		assert.Equal(c.expectedPrefix, f.GeneratedFilenamePrefix, c)
+	}
+}
+
+func TestOptionsSet(t *testing.T) {
+	assert := assert.New(t)
+	opts := NewOptions()
+	params := [][2]string{
+		{"suffix", "_stest.go"},
+		{"package", "pb"},
+		{"include_service", "Foo"},
+		{"include_service", "acme.v1.Bar"},
+		{"exclude_service", "Baz"},
+		{"template", "custom.tmpl"},
+		{"helper", "true"},
+	}
+	for _, p := range params {
+		assert.NoError(opts.Set(p[0], p[1]))
+	}
+	helper := true
+	assert.Equal(&Options{
+		Suffix:          "_stest.go",
+		Package:         "pb",
+		IncludeServices: []string{"Foo", "acme.v1.Bar"},
+		ExcludeServices: []string{"Baz"},
+		Template:        "custom.tmpl",
+		Helper:          &helper,
+	}, opts)
+}
+
+func TestOptionsSetError(t *testing.T) {
+	assert := assert.New(t)
+	cases := [][2]string{
+		{"unknown", "1"},
+		{"plugins", "grpc"},
+		{"package", ""},
+		{"suffix", "_stest"},
+		{"helper", "yes"},
+	}
+	for _, c := range cases {
+		assert.Error(NewOptions().Set(c[0], c[1]), c)
+	}
+	_, err := protogen.Options{ParamFunc: NewOptions().Set}.New(&pluginpb.CodeGeneratorRequest{
+		Parameter: proto.String("plugins=grpc"),
+	})
+	assert.EqualError(err, `unknown parameter "plugins"`)
+}
+
+func TestOptionsGenerates(t *testing.T) {
+	assert := assert.New(t)
+	req := &pluginpb.CodeGeneratorRequest{
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			{
+				Name:    proto.String("acme/v1/foo.proto"),
+				Package: proto.String("acme.v1"),
+				Options: &descriptorpb.FileOptions{GoPackage: proto.String("github.com/acme/api/v1")},
+				Service: []*descriptorpb.ServiceDescriptorProto{
+					{Name: proto.String("Foo")},
+					{Name: proto.String("Bar")},
+				},
+			},
+			{
+				Name:    proto.String("acme/v2/foo.proto"),
+				Package: proto.String("acme.v2"),
+				Options: &descriptorpb.FileOptions{GoPackage: proto.String("github.com/acme/api/v2")},
+				Service: []*descriptorpb.ServiceDescriptorProto{
+					{Name: proto.String("Foo")},
+					{Name: proto.String("Bar")},
+				},
+			},
+			{
+				Name:    proto.String("baz.proto"),
+				Options: &descriptorpb.FileOptions{GoPackage: proto.String("github.com/acme/api/baz")},
+				Service: []*descriptorpb.ServiceDescriptorProto{{Name: proto.String("Baz")}},
+			},
+		},
+	}
+	gen, err := protogen.Options{}.New(req)
+	assert.NoError(err)
+	opts := &Options{
+		IncludeServices: []string{"Foo", "acme.v1.Bar", "Baz"},
+		ExcludeServices: []string{"acme.v1.Foo", "Baz"},
+	}
+	v1, v2, baz := gen.Files[0].Services, gen.Files[1].Services, gen.Files[2].Services
+	assert.False(opts.generates(v1[0]))
+	assert.True(opts.generates(v2[0]))
+	assert.True(opts.generates(v1[1]))
+	assert.False(opts.generates(v2[1]))
+	assert.False(opts.generates(baz[0]))
+}